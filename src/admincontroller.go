@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// AdminController handles the web methods for administering the service
+type AdminController struct {
+	Srv *Server
+}
+
+// AddController adds the controller routes to the router
+func (c *AdminController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	router.Methods("GET").Path("/admin/loglevel").
+		Handler(Logger(c, http.HandlerFunc(c.handleGetLogLevel)))
+	router.Methods("PUT", "POST").Path("/admin/loglevel").
+		Handler(Logger(c, http.HandlerFunc(c.handleSetLogLevel)))
+	router.Methods("POST").Path("/admin/reload").
+		Handler(Logger(c, http.HandlerFunc(c.handleReload)))
+}
+
+// handleGetLogLevel handles the GET /admin/loglevel web method call
+func (c *AdminController) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	resp := api.LogLevelRequest{Level: GetLogLevel()}
+	resp.WriteTo(w)
+}
+
+// handleSetLogLevel handles the PUT /admin/loglevel web method call
+func (c *AdminController) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	req := api.LogLevelRequest{}
+	req.ReadFrom(r.Body)
+	old := GetLogLevel()
+	if err := SetLogLevel(req.Level); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	c.Srv.RecordConfigChange(&api.LogLevelRequest{Level: old}, &api.LogLevelRequest{Level: GetLogLevel()},
+		CallerFromRequest(r), "log level changed")
+	c.LogInfo("Log level changed", "level", GetLogLevel(), LogRequestID, RequestIDFromContext(r.Context()))
+	resp := api.LogLevelRequest{Level: GetLogLevel()}
+	resp.WriteTo(w)
+}
+
+// handleReload handles the /admin/reload web method call
+func (c *AdminController) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := c.Srv.ReloadConfig(CallerFromRequest(r), "reload requested"); err != nil {
+		http.Error(w, "Failed to reload configuration. "+err.Error(), 400)
+		return
+	}
+	c.Srv.Config().Redacted().WriteTo(w)
+}
+
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *AdminController) LogInfo(msg string, args ...interface{}) {
+	componentLog("AdminController").Info(msg, args...)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions recorded in the audit log
+const (
+	AuditRegister     = "register"   // A service was registered
+	AuditConfirm      = "confirm"    // An existing registration was confirmed
+	AuditText         = "text"       // The Text of a registration was changed
+	AuditDeregister   = "deregister" // A service was deregistered
+	AuditExpire       = "expire"     // A registration expired
+	AuditConfigChange = "config"     // The configuration was changed
+)
+
+// AuditRecord describes a single change recorded in the audit log
+type AuditRecord struct {
+	Time      time.Time   `json:"time"`                // Date and time of the change
+	Action    string      `json:"action"`              // Action that was performed
+	ID        string      `json:"id,omitempty"`        // ID of the service registration, if any
+	Caller    string      `json:"caller,omitempty"`    // Address of the caller that made the change
+	Owner     *PeerCred   `json:"owner,omitempty"`     // Credentials of the calling process, if known
+	RequestID string      `json:"requestId,omitempty"` // ID of the http request that made the change
+	Reason    string      `json:"reason,omitempty"`    // Reason for the change
+	Before    interface{} `json:"before,omitempty"`    // Values before the change
+	After     interface{} `json:"after,omitempty"`     // Values after the change
+}
+
+// Serialize serializes the entity and returns the serialized string
+func (e *AuditRecord) Serialize() (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
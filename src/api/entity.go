@@ -1,4 +1,5 @@
-package main
+// Package api defines the entities sent to and returned from the zcservice web API
+package api
 
 import (
 	"io"
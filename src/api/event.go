@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types published when service registrations change
+const (
+	EventRegistered   = "registered"   // A service was registered
+	EventDeregistered = "deregistered" // A service was deregistered
+	EventHealth       = "health"       // The health state of a service changed
+	EventText         = "text"         // The Text of a service changed
+)
+
+// Event describes a change to a service registration
+type Event struct {
+	Time        time.Time `json:"time"`             // Date and time of the event
+	Type        string    `json:"type"`             // Type of event
+	ID          string    `json:"id"`               // ID of the service registration
+	Name        string    `json:"name"`             // Service Instance Name
+	ServiceType string    `json:"serviceType"`      // Service Type
+	Health      string    `json:"health,omitempty"` // Health state of the service, if checked
+	Announced   bool      `json:"announced"`        // Indicates whether the service is currently announced
+}
+
+// Serialize serializes the entity and returns the serialized string
+func (e *Event) Serialize() (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -1,4 +1,4 @@
-package main
+package api
 
 import (
 	"encoding/json"
@@ -12,6 +12,7 @@ type GetRequest struct {
 	ServiceType string `json:"serviceType"` // The search service type
 	Domain      string `json:"domain"`      // The search domain.  For local networks, default of "local" is fine.
 	WaitTime    int    `json:"waitTime"`    // The maximum amount of time to wait for a response
+	OnlyHealthy bool   `json:"onlyHealthy"` // Only return instances that publish a healthy state, or no health state
 }
 
 // CreateResponse creates a response from this request
@@ -1,10 +1,11 @@
-package main
+package api
 
 import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
 // GetResponse holds the response data for a GetRequest call
@@ -14,6 +15,23 @@ type GetResponse struct {
 	Services    []ServiceItem `json:"services"`    // The list of services
 }
 
+// Find returns the service instance with the specified name, or nil if there is none.  The name is either
+// the full instance name, or the service name the instance was registered with through zcservice.
+func (e *GetResponse) Find(name string) *ServiceItem {
+	for i := range e.Services {
+		if e.Services[i].Name == name {
+			return &e.Services[i]
+		}
+	}
+	// Services registered through zcservice are named "name/host/port"
+	for i := range e.Services {
+		if strings.HasPrefix(e.Services[i].Name, name+"/") {
+			return &e.Services[i]
+		}
+	}
+	return nil
+}
+
 // ReadFrom reads the string from the reader and deserializes it into the entity values
 func (e *GetResponse) ReadFrom(r io.ReadCloser) error {
 	b, err := ioutil.ReadAll(r)
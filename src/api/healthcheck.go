@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Health states reported for a service registration
+const (
+	HealthUnknown   = "unknown"   // No check result yet
+	HealthHealthy   = "healthy"   // The health check is passing
+	HealthUnhealthy = "unhealthy" // The health check is failing
+)
+
+// HealthTextKey is the TXT record key used to publish the health state of a service
+const HealthTextKey = "health"
+
+// HealthCheck defines how the health of a registered service is checked
+type HealthCheck struct {
+	Type               string   `json:"type"`               // Type of check, either "tcp", "http" or "script"
+	Host               string   `json:"host"`               // Host to check.  Defaults to "127.0.0.1"
+	Path               string   `json:"path"`               // Path requested by an "http" check
+	ExpectedStatus     int      `json:"expectedStatus"`     // Status code expected by an "http" check.  Defaults to 200
+	Script             string   `json:"script"`             // Script run by a "script" check.  An exit code of 0 is healthy
+	Args               []string `json:"args"`               // Arguments passed to the script
+	Interval           int      `json:"interval"`           // Interval between checks in secs.  Defaults to 10
+	Timeout            int      `json:"timeout"`            // Maximum duration of a check in secs.  Defaults to 2
+	HealthyThreshold   int      `json:"healthyThreshold"`   // Consecutive passes before an unhealthy service is healthy.  Defaults to 2
+	UnhealthyThreshold int      `json:"unhealthyThreshold"` // Consecutive failures before a healthy service is unhealthy.  Defaults to 3
+	KeepAnnounced      bool     `json:"keepAnnounced"`      // Keep announcing the service while it is unhealthy, with health=unhealthy in the TXT record
+}
+
+// Validate checks the health check values
+func (c *HealthCheck) Validate() error {
+	switch c.Type {
+	case "tcp", "http":
+	case "script":
+		if c.Script == "" {
+			return errors.New("health check script is missing")
+		}
+	default:
+		return fmt.Errorf("invalid health check type '%s'", c.Type)
+	}
+	if c.Interval < 0 || c.Timeout < 0 || c.HealthyThreshold < 0 || c.UnhealthyThreshold < 0 {
+		return errors.New("health check interval, timeout and thresholds must not be negative")
+	}
+	return nil
+}
+
+// SetDefaults checks the values and sets the defaults
+func (c *HealthCheck) SetDefaults() {
+	if c.Host == "" {
+		c.Host = "127.0.0.1"
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.ExpectedStatus == 0 {
+		c.ExpectedStatus = 200
+	}
+	if c.Interval == 0 {
+		c.Interval = 10
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 2
+	}
+	if c.HealthyThreshold == 0 {
+		c.HealthyThreshold = 2
+	}
+	if c.UnhealthyThreshold == 0 {
+		c.UnhealthyThreshold = 3
+	}
+}
+
+// Equals returns whether the health checks are the same
+func (c *HealthCheck) Equals(i *HealthCheck) bool {
+	if c == nil || i == nil {
+		return c == i
+	}
+	if c.Type != i.Type || c.Host != i.Host || c.Path != i.Path || c.ExpectedStatus != i.ExpectedStatus ||
+		c.Script != i.Script || c.Interval != i.Interval || c.Timeout != i.Timeout ||
+		c.HealthyThreshold != i.HealthyThreshold || c.UnhealthyThreshold != i.UnhealthyThreshold ||
+		c.KeepAnnounced != i.KeepAnnounced {
+		return false
+	}
+	if len(c.Args) != len(i.Args) {
+		return false
+	}
+	for x := range c.Args {
+		if c.Args[x] != i.Args[x] {
+			return false
+		}
+	}
+	return true
+}
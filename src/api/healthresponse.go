@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// HealthResponse holds the response data for a /health/live or /health/ready call
+type HealthResponse struct {
+	Status       string        `json:"status"`                 // Overall status, either "ok" or "fail"
+	Uptime       float64       `json:"uptime"`                 // Time since the service started in secs
+	Version      string        `json:"version"`                // Version of zcservice
+	ConfigID     string        `json:"configId"`               // ID of this zcservice installation
+	Registration *HealthResult `json:"registration,omitempty"` // Status of the zcservice registration
+	Interfaces   []string      `json:"interfaces,omitempty"`   // Names of the usable multicast interfaces
+	SelfBrowse   *HealthResult `json:"selfBrowse,omitempty"`   // Result of browsing for the zcservice registration
+}
+
+// HealthResult holds the result of a single readiness check
+type HealthResult struct {
+	Status string `json:"status"`          // Status of the check, either "ok" or "fail"
+	Error  string `json:"error,omitempty"` // Reason the check failed
+}
+
+// IsOK returns whether the health response status is ok
+func (e *HealthResponse) IsOK() bool {
+	return e.Status == "ok"
+}
+
+// ReadFrom reads the string from the reader and deserializes it into the entity values
+func (e *HealthResponse) ReadFrom(r io.ReadCloser) error {
+	b, err := ioutil.ReadAll(r)
+	if err == nil {
+		if b != nil && len(b) != 0 {
+			err = json.Unmarshal(b, &e)
+		}
+	}
+	e.SetDefaults()
+	return err
+}
+
+// WriteTo serializes the entity and writes it to the http response
+func (e *HealthResponse) WriteTo(w http.ResponseWriter) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("content-type", "application/json")
+	if !e.IsOK() {
+		w.WriteHeader(503)
+	}
+	w.Write(b)
+	return nil
+}
+
+// Serialize serializes the entity and returns the serialized string
+func (e *HealthResponse) Serialize() (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Deserialize deserializes the specified string into the entity values
+func (e *HealthResponse) Deserialize(v string) error {
+	err := json.Unmarshal([]byte(v), &e)
+	e.SetDefaults()
+	return err
+}
+
+// SetDefaults checks the values and sets the defaults
+func (e *HealthResponse) SetDefaults() {
+	if e.Status == "" {
+		e.Status = "ok"
+	}
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"fmt"
+)
+
+// PeerCred holds the credentials the kernel reports for the peer of a Unix socket connection
+type PeerCred struct {
+	PID int `json:"pid"` // Process ID of the peer
+	UID int `json:"uid"` // User ID of the peer
+	GID int `json:"gid"` // Group ID of the peer
+}
+
+// String returns the credentials in a readable format
+func (c *PeerCred) String() string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("pid=%d uid=%d gid=%d", c.PID, c.UID, c.GID)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// RegisterRequest is the registration request data sent from a microservice
+type RegisterRequest struct {
+	ID          string       `json:"id"`          // ID of the service
+	Name        string       `json:"name"`        // Name of the service
+	PortNo      int          `json:"portNo"`      // Port number of the service
+	ServiceType string       `json:"serviceType"` // Type of the server
+	Subtypes    []string     `json:"subtypes"`    // Subtypes the service can also be browsed by, e.g. "_printer"
+	Domain      string       `json:"domain"`      // Service domain
+	Text        []string     `json:"text"`        // Additional service Text
+	PID         int          `json:"pid"`         // Process ID to watch.  The service is deregistered when this process exits
+	WatchOwner  bool         `json:"watchOwner"`  // Watch the process connected to the Unix socket instead of PID
+	HealthCheck *HealthCheck `json:"healthCheck"` // Health check that gates the announcement of the service
+	Host        string       `json:"host"`        // Host name of the service, if it runs on another machine
+	IPs         []string     `json:"ips"`         // IP addresses of Host.  Required if Host is specified
+	ExactName   bool         `json:"-"`           // Announce Name as the instance name as it is, without the host name and port
+}
+
+// CreateResponse creates a response to the current request
+func (e *RegisterRequest) CreateResponse() RegisterResponse {
+	return RegisterResponse{
+		ID: e.ID,
+	}
+}
+
+// ReadFrom reads the string from the reader and deserializes it into the entity values
+func (e *RegisterRequest) ReadFrom(r io.ReadCloser) error {
+	b, err := ioutil.ReadAll(r)
+	if err == nil {
+		if b != nil && len(b) != 0 {
+			err = json.Unmarshal(b, &e)
+		}
+	}
+	e.SetDefaults()
+	return err
+}
+
+// WriteTo serializes the entity and writes it to the http response
+func (e *RegisterRequest) WriteTo(w http.ResponseWriter) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Write(b)
+	return nil
+}
+
+// Serialize serializes the entity and returns the serialized string
+func (e *RegisterRequest) Serialize() (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Deserialize deserializes the specified string into the entity values
+func (e *RegisterRequest) Deserialize(v string) error {
+	err := json.Unmarshal([]byte(v), &e)
+	e.SetDefaults()
+	return err
+}
+
+// ValidateProxy checks the host and IP addresses of a service that runs on another machine
+func (e *RegisterRequest) ValidateProxy() error {
+	if e.Host == "" && len(e.IPs) == 0 {
+		return nil
+	}
+	if e.Host == "" {
+		return errors.New("host is missing")
+	}
+	if len(e.IPs) == 0 {
+		return errors.New("ips are missing")
+	}
+	for _, ip := range e.IPs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid ip address '%s'", ip)
+		}
+	}
+	return nil
+}
+
+// SetDefaults checks the values and sets the defaults
+func (e *RegisterRequest) SetDefaults() {
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"time"
+)
+
+// Sources of service registrations
+const (
+	SourceAPI    = "api"    // Registered through the web API
+	SourceSelf   = "self"   // The registration of this zcservice instance
+	SourceStatic = "static" // Declared in the services section of the configuration
+	SourceFile   = "file"   // Defined in a file in the services folder
+)
+
+// RegistrationItem represents a service registered with this zcservice instance
+type RegistrationItem struct {
+	ID          string    `json:"id"`                 // ID of the service registration
+	Name        string    `json:"name"`               // Service Instance Name
+	PortNo      int       `json:"portNo"`             // Port number the service is available on
+	ServiceType string    `json:"serviceType"`        // Service Type
+	Subtypes    []string  `json:"subtypes,omitempty"` // Service Subtypes
+	Domain      string    `json:"domain"`             // Domain name
+	Text        []string  `json:"text"`               // Service info served as a TXT record
+	LastContact time.Time `json:"lastContact"`        // Date and time of last contact
+	Owner       *PeerCred `json:"owner,omitempty"`    // Credentials of the registering process, if known
+	PID         int       `json:"pid,omitempty"`      // Process ID being watched, if any
+	Health      string    `json:"health,omitempty"`   // Health state, if the service has a health check
+	Announced   bool      `json:"announced"`          // Indicates whether the service is currently announced
+	Host        string    `json:"host,omitempty"`     // Host name of the service, if it runs on another machine
+	IPs         []string  `json:"ips,omitempty"`      // IP addresses of Host
+	Source      string    `json:"source"`             // Source of the registration, either "api", "self", "static" or "file"
+}
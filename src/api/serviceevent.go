@@ -0,0 +1,29 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Service event types published when the services found on the network change
+const (
+	ServiceAdded   = "added"   // A service instance was found
+	ServiceUpdated = "updated" // The port, addresses or Text of a service instance changed
+	ServiceRemoved = "removed" // A service instance is no longer found
+)
+
+// ServiceEvent describes a change to the instances of a service type found on the network
+type ServiceEvent struct {
+	Time    time.Time   `json:"time"`    // Date and time of the event
+	Type    string      `json:"type"`    // Type of event
+	Service ServiceItem `json:"service"` // The service instance, as last found
+}
+
+// Serialize serializes the entity and returns the serialized string
+func (e *ServiceEvent) Serialize() (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
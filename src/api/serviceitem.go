@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net"
+	"strings"
+)
+
+// ServiceItem represents the data returned by zeroconf from a service browse
+type ServiceItem struct {
+	Name     string   `json:"name"`     // Service Name
+	Port     int      `json:"port"`     // Service Port
+	HostName string   `json:"hostname"` // Host machine DNS name
+	Service  string   `json:"type"`     // Service name
+	Domain   string   `json:"domain"`   // If blank, assumes "local"
+	Text     []string `json:"text"`     // Service info served as a TXT record
+	AddrIPv4 []net.IP `json:"ipv4"`     // Host machine IPv4 address
+	AddrIPv6 []net.IP `json:"ipv6"`     // Host machine IPv6 address
+}
+
+// TextValue returns the value of the specified key in the TXT record, and whether the key was found
+func (i *ServiceItem) TextValue(key string) (string, bool) {
+	for _, v := range i.Text {
+		if TextKey(v) == key {
+			return strings.TrimPrefix(v[len(key):], "="), true
+		}
+	}
+	return "", false
+}
+
+// IsHealthy returns whether the service is healthy.
+// Services that do not publish their health state are treated as healthy.
+func (i *ServiceItem) IsHealthy() bool {
+	h, ok := i.TextValue(HealthTextKey)
+	return !ok || h == HealthHealthy
+}
+
+// TextKey returns the key of a Key=Value TXT record string
+func TextKey(v string) string {
+	if i := strings.Index(v, "="); i >= 0 {
+		return v[:i]
+	}
+	return v
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// AuditController handles the web methods for reading the audit log
+type AuditController struct {
+	Srv *Server
+}
+
+// AddController adds the controller routes to the router
+func (c *AuditController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	router.Methods("GET").Path("/audit").
+		Handler(Logger(c, http.HandlerFunc(c.handleAudit)))
+}
+
+// handleAudit handles the /audit web method call
+func (c *AuditController) handleAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	resp := api.AuditResponse{Records: c.Srv.audit.Records(q.Get("id"))}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit.", 400)
+			return
+		}
+		if n < len(resp.Records) {
+			resp.Records = resp.Records[len(resp.Records)-n:]
+		}
+	}
+	resp.WriteTo(w)
+}
+
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *AuditController) LogInfo(msg string, args ...interface{}) {
+	componentLog("AuditController").Info(msg, args...)
+}
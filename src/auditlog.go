@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// AuditConfig defines where the audit log is kept
+type AuditConfig struct {
+	File       string `json:"file,omitempty"`       // Path of the JSON lines file the audit log is appended to.  If blank, records are only kept in memory
+	MaxSize    int    `json:"maxSize,omitempty"`    // Maximum size of the file in MB before it is rotated.  Defaults to 10
+	MaxFiles   int    `json:"maxFiles,omitempty"`   // Number of rotated files to keep.  Defaults to 5
+	BufferSize int    `json:"bufferSize,omitempty"` // Number of records kept in memory.  Defaults to 1000
+}
+
+// SetDefaults checks the values and sets the defaults
+func (c *AuditConfig) SetDefaults() {
+	if c.MaxSize <= 0 {
+		c.MaxSize = 10
+	}
+	if c.MaxFiles <= 0 {
+		c.MaxFiles = 5
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1000
+	}
+}
+
+// AuditLog is an append-only log of registration and configuration changes.
+// The most recent records are kept in a ring buffer, and all records are
+// optionally appended to a rotating JSON lines file.
+type AuditLog struct {
+	Config  AuditConfig       // Audit log configuration
+	records []api.AuditRecord // Ring buffer of the most recent records
+	next    int               // Position of the next record in the ring buffer
+	full    bool              // Indicates whether the ring buffer has wrapped
+	file    *os.File          // Audit log file
+	size    int64             // Current size of the audit log file
+	lock    sync.Mutex        // Mutex lock for the buffer and file
+}
+
+// NewAuditLog creates a new audit log with the specified configuration
+func NewAuditLog(c AuditConfig) *AuditLog {
+	c.SetDefaults()
+	return &AuditLog{
+		Config:  c,
+		records: make([]api.AuditRecord, c.BufferSize),
+	}
+}
+
+// Record appends a record to the audit log
+func (a *AuditLog) Record(r api.AuditRecord) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.records[a.next] = r
+	a.next = (a.next + 1) % len(a.records)
+	if a.next == 0 {
+		a.full = true
+	}
+	if a.Config.File == "" {
+		return nil
+	}
+	return a.write(r)
+}
+
+// Records returns the records held in memory, oldest first.
+// If id is not blank, only records for that registration are returned.
+func (a *AuditLog) Records(id string) []api.AuditRecord {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	l := []api.AuditRecord{}
+	add := func(r []api.AuditRecord) {
+		for _, v := range r {
+			if id == "" || v.ID == id {
+				l = append(l, v)
+			}
+		}
+	}
+	if a.full {
+		add(a.records[a.next:])
+	}
+	add(a.records[:a.next])
+	return l
+}
+
+// SetConfig changes the audit log configuration, keeping the records held in memory
+func (a *AuditLog) SetConfig(c AuditConfig) {
+	c.SetDefaults()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.file != nil && c.File != a.Config.File {
+		a.file.Close()
+		a.file = nil
+	}
+	if c.BufferSize != a.Config.BufferSize {
+		l := a.records[a.next:]
+		if !a.full {
+			l = nil
+		}
+		l = append(append([]api.AuditRecord{}, l...), a.records[:a.next]...)
+		if len(l) > c.BufferSize {
+			l = l[len(l)-c.BufferSize:]
+		}
+		a.records = make([]api.AuditRecord, c.BufferSize)
+		copy(a.records, l)
+		a.next = len(l) % c.BufferSize
+		a.full = len(l) == c.BufferSize
+	}
+	a.Config = c
+}
+
+// Close closes the audit log file
+func (a *AuditLog) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// write appends the record to the audit log file, rotating the file if it is full.
+// The lock must be held.
+func (a *AuditLog) write(r api.AuditRecord) error {
+	v, err := r.Serialize()
+	if err != nil {
+		return err
+	}
+	b := []byte(v + "\n")
+	if a.file != nil && a.size+int64(len(b)) > int64(a.Config.MaxSize)*1024*1024 {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	if a.file == nil {
+		if err := os.MkdirAll(filepath.Dir(a.Config.File), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(a.Config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		a.file = f
+		a.size = fi.Size()
+	}
+	n, err := a.file.Write(b)
+	a.size += int64(n)
+	return err
+}
+
+// rotate closes the audit log file and shifts it and the older files along,
+// removing the oldest.  The lock must be held.
+func (a *AuditLog) rotate() error {
+	a.file.Close()
+	a.file = nil
+	p := a.Config.File
+	os.Remove(fmt.Sprintf("%s.%d", p, a.Config.MaxFiles))
+	for i := a.Config.MaxFiles - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", p, i), fmt.Sprintf("%s.%d", p, i+1))
+	}
+	return os.Rename(p, p+".1")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// AuthPolicy defines the access rules that are applied to requests received on a listener
+type AuthPolicy struct {
+	Allow       []string     `json:"allow,omitempty"`       // IP addresses or CIDR networks allowed to connect.  If empty, all addresses are allowed
+	Tokens      []string     `json:"tokens,omitempty"`      // Bearer tokens.  If not empty, requests must supply one of these tokens
+	ClientNames []string     `json:"clientNames,omitempty"` // Client certificate common names allowed to connect.  If empty, any verified certificate is allowed
+	UIDs        []int        `json:"uids,omitempty"`        // Unix socket peer user IDs allowed to connect.  If empty, all users are allowed
+	GIDs        []int        `json:"gids,omitempty"`        // Unix socket peer group IDs allowed to connect.  If empty, all groups are allowed
+	PIDs        []int        `json:"pids,omitempty"`        // Unix socket peer process IDs allowed to connect.  If empty, all processes are allowed
+	allowNets   []*net.IPNet // Parsed Allow networks
+}
+
+// Validate checks the policy values and prepares the policy for use
+func (p *AuthPolicy) Validate() error {
+	p.allowNets = nil
+	for _, a := range p.Allow {
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return fmt.Errorf("invalid allow address '%s'", a)
+			}
+			if ip.To4() != nil {
+				a = a + "/32"
+			} else {
+				a = a + "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return fmt.Errorf("invalid allow network '%s'", a)
+		}
+		p.allowNets = append(p.allowNets, n)
+	}
+	return nil
+}
+
+// Handler wraps the specified handler so that requests are only passed
+// through if they satisfy the policy
+func (p *AuthPolicy) Handler(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch p.Check(r.RemoteAddr, r.TLS, PeerCredFromContext(r.Context()), r.Header.Get("Authorization")) {
+		case 403:
+			http.Error(w, "Forbidden", 403)
+			return
+		case 401:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// Check applies the policy to a request from the remote address, with the TLS connection state, Unix socket
+// peer credentials and Authorization header of the request, any of which may be empty.  Returns 0 if the
+// request is allowed, otherwise the HTTP status code of the error, either 403 or 401.
+func (p *AuthPolicy) Check(remoteAddr string, cs *tls.ConnectionState, pc *api.PeerCred, authorization string) int {
+	if !p.isAddrAllowed(remoteAddr) || !p.isClientAllowed(cs) || !p.isPeerAllowed(pc) {
+		return 403
+	}
+	if !p.isTokenValid(authorization) {
+		return 401
+	}
+	return 0
+}
+
+// isAddrAllowed returns whether the remote address is in the allowed networks
+func (p *AuthPolicy) isAddrAllowed(remoteAddr string) bool {
+	if len(p.allowNets) == 0 {
+		return true
+	}
+	h, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		h = remoteAddr
+	}
+	ip := net.ParseIP(h)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.allowNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isClientAllowed returns whether the verified client certificate is in the allowed client names
+func (p *AuthPolicy) isClientAllowed(cs *tls.ConnectionState) bool {
+	if len(p.ClientNames) == 0 {
+		return true
+	}
+	if cs == nil || len(cs.VerifiedChains) == 0 {
+		return false
+	}
+	cn := cs.VerifiedChains[0][0].Subject.CommonName
+	for _, n := range p.ClientNames {
+		if n == cn {
+			return true
+		}
+	}
+	return false
+}
+
+// isPeerAllowed returns whether the Unix socket peer credentials satisfy the allowed user, group and process IDs
+func (p *AuthPolicy) isPeerAllowed(pc *api.PeerCred) bool {
+	if len(p.UIDs) == 0 && len(p.GIDs) == 0 && len(p.PIDs) == 0 {
+		return true
+	}
+	if pc == nil {
+		return false
+	}
+	return containsID(p.UIDs, pc.UID) && containsID(p.GIDs, pc.GID) && containsID(p.PIDs, pc.PID)
+}
+
+// containsID returns whether the id is in the list, or the list is empty
+func containsID(l []int, id int) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, v := range l {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isTokenValid returns whether the Authorization header carries one of the allowed bearer tokens
+func (p *AuthPolicy) isTokenValid(h string) bool {
+	if len(p.Tokens) == 0 {
+		return true
+	}
+	if !strings.HasPrefix(h, "Bearer ") {
+		return false
+	}
+	t := []byte(strings.TrimSpace(h[7:]))
+	for _, v := range p.Tokens {
+		if subtle.ConstantTimeCompare(t, []byte(v)) == 1 {
+			return true
+		}
+	}
+	return false
+}
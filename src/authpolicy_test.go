@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// clientCert returns a TLS connection state with a verified client certificate for the common name
+func clientCert(cn string) *tls.ConnectionState {
+	c := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{c}}}
+}
+
+func TestAuthPolicyValidate(t *testing.T) {
+	tests := []struct {
+		allow []string
+		fail  bool
+	}{
+		{nil, false},
+		{[]string{"10.0.0.1", "192.168.0.0/16", "::1", "fd00::/8"}, false},
+		{[]string{"10.0.0"}, true},
+		{[]string{"10.0.0.0/33"}, true},
+		{[]string{"localhost"}, true},
+	}
+	for _, tt := range tests {
+		p := AuthPolicy{Allow: tt.allow}
+		if err := p.Validate(); (err != nil) != tt.fail {
+			t.Errorf("Validate(%v) error = %v, want failure %v", tt.allow, err, tt.fail)
+		}
+	}
+}
+
+func TestAuthPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AuthPolicy
+		addr   string
+		cs     *tls.ConnectionState
+		pc     *api.PeerCred
+		auth   string
+		want   int
+	}{
+		{"empty policy allows everything", AuthPolicy{}, "203.0.113.1:1234", nil, nil, "", 0},
+		{"address in network", AuthPolicy{Allow: []string{"10.0.0.0/8"}}, "10.1.2.3:1234", nil, nil, "", 0},
+		{"address outside network", AuthPolicy{Allow: []string{"10.0.0.0/8"}}, "192.168.1.1:1234", nil, nil, "", 403},
+		{"single address", AuthPolicy{Allow: []string{"10.0.0.1"}}, "10.0.0.1:1234", nil, nil, "", 0},
+		{"IPv6 address", AuthPolicy{Allow: []string{"::1"}}, "[::1]:1234", nil, nil, "", 0},
+		{"address without port", AuthPolicy{Allow: []string{"10.0.0.1"}}, "10.0.0.1", nil, nil, "", 0},
+		{"Unix socket address with allow list", AuthPolicy{Allow: []string{"10.0.0.1"}}, "@", nil, nil, "", 403},
+		{"valid token", AuthPolicy{Tokens: []string{"secret"}}, "10.0.0.1:1", nil, nil, "Bearer secret", 0},
+		{"wrong token", AuthPolicy{Tokens: []string{"secret"}}, "10.0.0.1:1", nil, nil, "Bearer other", 401},
+		{"missing token", AuthPolicy{Tokens: []string{"secret"}}, "10.0.0.1:1", nil, nil, "", 401},
+		{"basic authorization", AuthPolicy{Tokens: []string{"secret"}}, "10.0.0.1:1", nil, nil, "Basic secret", 401},
+		{"forbidden before unauthorized", AuthPolicy{Allow: []string{"10.0.0.1"}, Tokens: []string{"secret"}}, "10.0.0.2:1", nil, nil, "", 403},
+		{"allowed client name", AuthPolicy{ClientNames: []string{"orders"}}, "10.0.0.1:1", clientCert("orders"), nil, "", 0},
+		{"other client name", AuthPolicy{ClientNames: []string{"orders"}}, "10.0.0.1:1", clientCert("billing"), nil, "", 403},
+		{"no client certificate", AuthPolicy{ClientNames: []string{"orders"}}, "10.0.0.1:1", &tls.ConnectionState{}, nil, "", 403},
+		{"allowed uid", AuthPolicy{UIDs: []int{1000}}, "@", nil, &api.PeerCred{UID: 1000, GID: 5, PID: 10}, "", 0},
+		{"other uid", AuthPolicy{UIDs: []int{1000}}, "@", nil, &api.PeerCred{UID: 1001, GID: 5, PID: 10}, "", 403},
+		{"uid and gid both required", AuthPolicy{UIDs: []int{1000}, GIDs: []int{5}}, "@", nil, &api.PeerCred{UID: 1000, GID: 6, PID: 10}, "", 403},
+		{"allowed pid", AuthPolicy{PIDs: []int{10, 11}}, "@", nil, &api.PeerCred{PID: 11}, "", 0},
+		{"no peer credentials", AuthPolicy{UIDs: []int{0}}, "10.0.0.1:1", nil, nil, "", 403},
+	}
+	for _, tt := range tests {
+		p := tt.policy
+		if err := p.Validate(); err != nil {
+			t.Fatalf("%s: Validate() error = %v", tt.name, err)
+		}
+		if got := p.Check(tt.addr, tt.cs, tt.pc, tt.auth); got != tt.want {
+			t.Errorf("%s: Check() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
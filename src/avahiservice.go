@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// avahiServiceGroup is the service-group element of an Avahi .service file
+type avahiServiceGroup struct {
+	XMLName  xml.Name       `xml:"service-group"`
+	Name     avahiName      `xml:"name"`    // Name of the services
+	Services []avahiService `xml:"service"` // Services in the group
+}
+
+// avahiName is the name element of an Avahi .service file
+type avahiName struct {
+	Value            string `xml:",chardata"`              // Name of the services
+	ReplaceWildcards string `xml:"replace-wildcards,attr"` // If "yes", %h in the name is replaced with the host name
+}
+
+// avahiService is a service element of an Avahi .service file
+type avahiService struct {
+	Protocol   string           `xml:"protocol,attr"` // Protocol, either "ipv4", "ipv6" or "any".  Only services on another machine can be limited to one
+	Type       string           `xml:"type"`          // Service type, e.g. "_http._tcp"
+	Subtypes   []string         `xml:"subtype"`       // Service subtypes, e.g. "_printer._sub._http._tcp"
+	DomainName string           `xml:"domain-name"`   // Domain name
+	HostName   string           `xml:"host-name"`     // Host name of the service, if it runs on another machine
+	Port       string           `xml:"port"`          // Port number
+	TxtRecords []avahiTxtRecord `xml:"txt-record"`    // TXT record entries
+}
+
+// avahiTxtRecord is a txt-record element of an Avahi .service file
+type avahiTxtRecord struct {
+	Value       string `xml:",chardata"`         // Entry, in key=value format
+	ValueFormat string `xml:"value-format,attr"` // Format of the value, either "text", "binary-hex" or "binary-base64"
+}
+
+// readAvahiServiceFile reads the services defined in an Avahi .service file.  Each service
+// element becomes a service definition, with an ID derived from the file name and position.
+func readAvahiServiceFile(path string) ([]ServiceDefinition, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g := avahiServiceGroup{}
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	if err := dec.Decode(&g); err != nil {
+		var se *xml.SyntaxError
+		if errors.As(err, &se) {
+			return nil, ConfigErrors{{Line: se.Line, Message: se.Msg}}
+		}
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+
+	name := strings.TrimSpace(g.Name.Value)
+	if g.Name.ReplaceWildcards == "yes" {
+		hn, _ := os.Hostname()
+		name = strings.Replace(name, "%h", strings.Split(hn, ".")[0], -1)
+	}
+	if name == "" {
+		return nil, ConfigErrors{{Path: "name", Message: "service group name is missing"}}
+	}
+	if len(g.Services) == 0 {
+		return nil, ConfigErrors{{Path: "service", Message: "service group has no services"}}
+	}
+
+	defs := []ServiceDefinition{}
+	errs := ConfigErrors{}
+	for i, s := range g.Services {
+		d, err := s.definition(name)
+		if err != nil {
+			errs = append(errs, &ConfigError{Path: fmt.Sprintf("service[%d]", i), Message: err.Error()})
+			continue
+		}
+		h := sha1.Sum([]byte(fmt.Sprintf("%s#%d", filepath.Base(path), i)))
+		d.ID = hex.EncodeToString(h[:16])
+		defs = append(defs, *d)
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return defs, nil
+}
+
+// definition returns the service definition of the Avahi service.  The service is announced
+// with the name as it is, so that it keeps the name it had when avahi-daemon announced it.
+func (s *avahiService) definition(name string) (*ServiceDefinition, error) {
+	d := &ServiceDefinition{
+		Name:        name,
+		ServiceType: strings.TrimSpace(s.Type),
+		Domain:      strings.TrimSpace(s.DomainName),
+		ExactName:   true,
+	}
+	proto := strings.TrimSpace(s.Protocol)
+	switch proto {
+	case "", "any", "ipv4", "ipv6":
+	default:
+		return nil, fmt.Errorf("invalid protocol '%s'", s.Protocol)
+	}
+	if d.ServiceType == "" {
+		return nil, errors.New("service type is missing")
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(s.Port))
+	if err != nil {
+		return nil, fmt.Errorf("invalid port '%s'", s.Port)
+	}
+	d.PortNo = p
+
+	// Avahi subtypes are full names, e.g. "_printer._sub._http._tcp", but only the subtype is announced
+	for _, st := range s.Subtypes {
+		st = strings.TrimSpace(st)
+		if i := strings.Index(st, "._sub."); i > 0 {
+			st = st[:i]
+		}
+		d.Subtypes = append(d.Subtypes, st)
+	}
+
+	for _, t := range s.TxtRecords {
+		v, err := t.text()
+		if err != nil {
+			return nil, err
+		}
+		d.Text = append(d.Text, v)
+	}
+
+	// A service on another machine is announced with that machine's addresses, which must be resolvable.
+	// The services on this machine are announced on every address, so they cannot be limited to one protocol.
+	hn := strings.TrimSuffix(strings.TrimSpace(s.HostName), ".")
+	if hn == "" {
+		if proto == "ipv4" || proto == "ipv6" {
+			return nil, fmt.Errorf("protocol '%s' is only supported with a host-name", proto)
+		}
+		return d, d.Validate()
+	}
+	ips, err := net.LookupHost(hn)
+	if err != nil || len(ips) == 0 {
+		return nil, fmt.Errorf("cannot resolve the addresses of host-name '%s'", hn)
+	}
+	d.Host = hn
+	d.IPs = filterProtocol(ips, proto)
+	if len(d.IPs) == 0 {
+		return nil, fmt.Errorf("host-name '%s' has no %s addresses", hn, proto)
+	}
+	return d, d.Validate()
+}
+
+// filterProtocol returns the IP addresses of the Avahi protocol, either "ipv4", "ipv6" or "any"
+func filterProtocol(ips []string, proto string) []string {
+	if proto != "ipv4" && proto != "ipv6" {
+		return ips
+	}
+	l := []string{}
+	for _, v := range ips {
+		ip := net.ParseIP(v)
+		if ip != nil && (ip.To4() != nil) == (proto == "ipv4") {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// text returns the TXT record entry, decoding it if it is in a binary format
+func (t *avahiTxtRecord) text() (string, error) {
+	switch t.ValueFormat {
+	case "", "text":
+		return t.Value, nil
+	case "binary-hex":
+		// Only the value after the key is encoded
+		k, v := splitTxtRecord(t.Value)
+		b, err := hex.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("invalid hex txt-record '%s'", t.Value)
+		}
+		return k + string(b), nil
+	case "binary-base64":
+		k, v := splitTxtRecord(t.Value)
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 txt-record '%s'", t.Value)
+		}
+		return k + string(b), nil
+	}
+	return "", fmt.Errorf("invalid txt-record value-format '%s'", t.ValueFormat)
+}
+
+// splitTxtRecord splits a TXT record entry into the key, including the "=", and the value
+func splitTxtRecord(v string) (string, string) {
+	if i := strings.Index(v, "="); i >= 0 {
+		return v[:i+1], v[i+1:]
+	}
+	return "", v
+}
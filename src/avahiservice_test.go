@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadAvahiServiceFile(t *testing.T) {
+	host, _ := os.Hostname()
+	host = strings.Split(host, ".")[0]
+	tests := []struct {
+		name    string
+		content string
+		want    []ServiceDefinition
+	}{
+		{"ssh", `<?xml version="1.0" standalone='no'?>
+<!DOCTYPE service-group SYSTEM "avahi-service.dtd">
+<service-group>
+  <name replace-wildcards="yes">%h SSH</name>
+  <service>
+    <type>_ssh._tcp</type>
+    <port>22</port>
+  </service>
+</service-group>`, []ServiceDefinition{
+			{Name: host + " SSH", ServiceType: "_ssh._tcp", PortNo: 22, ExactName: true},
+		}},
+		{"wildcards not replaced", `<service-group>
+  <name>Printer on %h</name>
+  <service protocol="any">
+    <type>_ipp._tcp</type>
+    <subtype>_universal._sub._ipp._tcp</subtype>
+    <domain-name>example.com</domain-name>
+    <port>631</port>
+    <txt-record>rp=printers/lab</txt-record>
+    <txt-record value-format="binary-hex">note=6c6162</txt-record>
+    <txt-record value-format="binary-base64">pdl=YXBwbGljYXRpb24vcGRm</txt-record>
+    <txt-record>color</txt-record>
+  </service>
+</service-group>`, []ServiceDefinition{
+			{Name: "Printer on %h", ServiceType: "_ipp._tcp", Subtypes: []string{"_universal"}, Domain: "example.com", PortNo: 631,
+				Text: []string{"rp=printers/lab", "note=lab", "pdl=application/pdf", "color"}, ExactName: true},
+		}},
+		{"several services", `<service-group>
+  <name>Web</name>
+  <service><type>_http._tcp</type><port>80</port></service>
+  <service><type>_https._tcp</type><port>443</port></service>
+</service-group>`, []ServiceDefinition{
+			{Name: "Web", ServiceType: "_http._tcp", PortNo: 80, ExactName: true},
+			{Name: "Web", ServiceType: "_https._tcp", PortNo: 443, ExactName: true},
+		}},
+	}
+	for _, tt := range tests {
+		defs, err := readAvahiServiceFile(writeTestFile(t, tt.name+".service", tt.content))
+		if err != nil {
+			t.Errorf("%s: readAvahiServiceFile() error = %v", tt.name, err)
+			continue
+		}
+		if len(defs) != len(tt.want) {
+			t.Errorf("%s: readAvahiServiceFile() = %d services, want %d", tt.name, len(defs), len(tt.want))
+			continue
+		}
+		ids := map[string]bool{}
+		for i, d := range defs {
+			if d.ID == "" || ids[d.ID] {
+				t.Errorf("%s: service %d has a blank or duplicate id %q", tt.name, i, d.ID)
+			}
+			ids[d.ID] = true
+			d.ID = ""
+			if !reflect.DeepEqual(d, tt.want[i]) {
+				t.Errorf("%s: service %d = %+v, want %+v", tt.name, i, d, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestReadAvahiServiceFileIDs(t *testing.T) {
+	content := `<service-group><name>Web</name><service><type>_http._tcp</type><port>80</port></service></service-group>`
+	a, err := readAvahiServiceFile(writeTestFile(t, "web.service", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := readAvahiServiceFile(writeTestFile(t, "web.service", strings.Replace(content, "80", "8080", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := readAvahiServiceFile(writeTestFile(t, "other.service", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a[0].ID != b[0].ID {
+		t.Errorf("id changed when the file changed: %q, %q", a[0].ID, b[0].ID)
+	}
+	if a[0].ID == c[0].ID {
+		t.Errorf("files with different names have the same id %q", a[0].ID)
+	}
+}
+
+func TestReadAvahiServiceFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		path    string
+	}{
+		{"syntax error", "<service-group>\n  <name>Web</name>\n  <service>\n</service-group>", 4, ""},
+		{"missing name", `<service-group><service><type>_http._tcp</type><port>80</port></service></service-group>`, 0, "name"},
+		{"no services", `<service-group><name>Web</name></service-group>`, 0, "service"},
+		{"missing type", `<service-group><name>Web</name><service><port>80</port></service></service-group>`, 0, "service[0]"},
+		{"invalid port", `<service-group><name>Web</name><service><type>_http._tcp</type><port>http</port></service></service-group>`, 0, "service[0]"},
+		{"port out of range", `<service-group><name>Web</name><service><type>_http._tcp</type><port>70000</port></service></service-group>`, 0, "service[0]"},
+		{"invalid value-format", `<service-group><name>Web</name><service><type>_http._tcp</type><port>80</port><txt-record value-format="rot13">a=b</txt-record></service></service-group>`, 0, "service[0]"},
+		{"invalid hex", `<service-group><name>Web</name><service><type>_http._tcp</type><port>80</port><txt-record value-format="binary-hex">a=zz</txt-record></service></service-group>`, 0, "service[0]"},
+		{"invalid protocol", `<service-group><name>Web</name><service protocol="ipx"><type>_http._tcp</type><port>80</port></service></service-group>`, 0, "service[0]"},
+		{"protocol without host-name", `<service-group><name>Web</name><service protocol="ipv4"><type>_http._tcp</type><port>80</port></service></service-group>`, 0, "service[0]"},
+		{"second service in error", `<service-group><name>Web</name><service><type>_http._tcp</type><port>80</port></service><service><type>_https._tcp</type></service></service-group>`, 0, "service[1]"},
+	}
+	for _, tt := range tests {
+		_, err := readAvahiServiceFile(writeTestFile(t, "test.service", tt.content))
+		var errs ConfigErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%s: readAvahiServiceFile() error = %v, want one ConfigError", tt.name, err)
+			continue
+		}
+		if errs[0].Line != tt.line || errs[0].Path != tt.path {
+			t.Errorf("%s: error = line %d %q (%v), want line %d %q", tt.name, errs[0].Line, errs[0].Path, errs[0], tt.line, tt.path)
+		}
+	}
+}
+
+func TestFilterProtocol(t *testing.T) {
+	ips := []string{"10.0.0.1", "fe80::1", "192.168.1.1", "::1"}
+	tests := []struct {
+		proto string
+		want  []string
+	}{
+		{"", ips},
+		{"any", ips},
+		{"ipv4", []string{"10.0.0.1", "192.168.1.1"}},
+		{"ipv6", []string{"fe80::1", "::1"}},
+	}
+	for _, tt := range tests {
+		if got := filterProtocol(ips, tt.proto); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterProtocol(%q) = %v, want %v", tt.proto, got, tt.want)
+		}
+	}
+}
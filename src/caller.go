@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+// CallerFromRequest returns the caller details of the http request
+func CallerFromRequest(r *http.Request) registry.Caller {
+	return registry.Caller{
+		Addr:      r.RemoteAddr,
+		Owner:     PeerCredFromContext(r.Context()),
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
@@ -0,0 +1,231 @@
+// Package client calls the web API of a zcservice instance to register and discover services
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// DefaultAddress is the address of a zcservice instance running with the default settings
+const DefaultAddress = "http://127.0.0.1:20404"
+
+// Client calls the web API of a zcservice instance
+type Client struct {
+	BaseURL    string       // URL of the zcservice instance, e.g. "http://127.0.0.1:20404"
+	Token      string       // Bearer token sent with each request, if the listener requires one
+	HTTPClient *http.Client // HTTP client used to send requests
+}
+
+// New creates a new client for the zcservice instance at the specified address.
+// The address is either a URL, e.g. "http://127.0.0.1:20404", or the path of a Unix
+// socket listener, e.g. "unix:///run/zcservice.sock".  If blank, DefaultAddress is used.
+func New(addr string) *Client {
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	if strings.HasPrefix(addr, "unix://") {
+		path := strings.TrimPrefix(addr, "unix://")
+		d := net.Dialer{}
+		return &Client{
+			BaseURL: "http://zcservice",
+			HTTPClient: &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, network string, a string) (net.Conn, error) {
+						return d.DialContext(ctx, "unix", path)
+					},
+				},
+			},
+		}
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(addr, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Browse searches the network for services matching the search criteria
+func (c *Client) Browse(ctx context.Context, r api.GetRequest) (*api.GetResponse, error) {
+	resp := api.GetResponse{}
+	if err := c.call(ctx, "POST", "/service/get", &r, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Lookup searches the network for the named instance of the service type.  The name is either
+// the full instance name, or the service name it was registered with through zcservice.
+// Returns ErrNotFound if the instance cannot be found.
+func (c *Client) Lookup(ctx context.Context, serviceType string, name string) (*api.ServiceItem, error) {
+	resp, err := c.Browse(ctx, api.GetRequest{ServiceType: serviceType})
+	if err != nil {
+		return nil, err
+	}
+	if s := resp.Find(name); s != nil {
+		return s, nil
+	}
+	return nil, ErrNotFound
+}
+
+// List returns the services registered with the zcservice instance
+func (c *Client) List(ctx context.Context) (*api.ListResponse, error) {
+	resp := api.ListResponse{}
+	if err := c.call(ctx, "GET", "/service/list", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Deregister removes the service registration with the specified ID
+func (c *Client) Deregister(ctx context.Context, id string) error {
+	return c.call(ctx, "DELETE", "/service/remove/"+url.PathEscape(id), nil, nil)
+}
+
+// SetText changes the Text of a registered service without registering it again
+func (c *Client) SetText(ctx context.Context, id string, text []string) error {
+	return c.call(ctx, "PATCH", "/service/"+url.PathEscape(id)+"/text", &api.TextRequest{Text: text}, nil)
+}
+
+// Watch returns a channel that receives the registration events of the zcservice instance
+// for the service type, or for all service types if it is blank.  Unlike WatchServices and
+// discovery.Watch, it does not search the network: it reports the services being registered
+// with, and deregistered from, this zcservice instance.  The channel is closed when the
+// context is cancelled or the connection to zcservice is lost.
+func (c *Client) Watch(ctx context.Context, serviceType string) (<-chan api.Event, error) {
+	resp, err := c.do(ctx, "GET", "/service/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan api.Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			e := api.Event{}
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				continue
+			}
+			if serviceType != "" && e.ServiceType != serviceType {
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// WatchServices returns a channel that receives an event each time an instance of the service type in the
+// request is found on the network, changes or is no longer found.  zcservice searches for the services at
+// the specified interval, or every 10 seconds if it is 0.  It is the client equivalent of discovery.Watch;
+// use Watch for the registration events of the zcservice instance.  The channel is closed when the context is
+// cancelled or the connection to zcservice is lost.
+func (c *Client) WatchServices(ctx context.Context, r api.GetRequest, interval time.Duration) (<-chan api.ServiceEvent, error) {
+	q := url.Values{}
+	q.Set("type", r.ServiceType)
+	if r.Domain != "" {
+		q.Set("domain", r.Domain)
+	}
+	if r.OnlyHealthy {
+		q.Set("onlyHealthy", "true")
+	}
+	if interval > 0 {
+		q.Set("interval", strconv.Itoa(int(interval.Seconds())))
+	}
+	resp, err := c.do(ctx, "GET", "/service/watch?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan api.ServiceEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		sc := bufio.NewScanner(resp.Body)
+		sc.Buffer(nil, 1024*1024)
+		for sc.Scan() {
+			e := api.ServiceEvent{}
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// call sends the request entity to the API method and reads the response into the response entity.
+// Either entity can be nil.
+func (c *Client) call(ctx context.Context, method string, path string, req interface{}, resp interface{}) error {
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	r, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if resp == nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, resp)
+}
+
+// do sends the request to the API method and returns the response.
+// Returns an *APIError if zcservice responds with an error status.
+func (c *Client) do(ctx context.Context, method string, path string, body io.Reader) (*http.Response, error) {
+	if c.BaseURL == "" {
+		return nil, errors.New("zcservice address is missing")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("content-type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
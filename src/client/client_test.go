@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	all := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrConflict, ErrUnavailable}
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusInternalServerError, ErrUnavailable},
+		{http.StatusServiceUnavailable, ErrUnavailable},
+		{http.StatusTeapot, nil},
+	}
+	for _, tt := range tests {
+		// The error is wrapped, as it is by callers that add context
+		err := fmt.Errorf("register: %w", &APIError{StatusCode: tt.status, Message: "failed"})
+		for _, target := range all {
+			if got := errors.Is(err, target); got != (target == tt.want) {
+				t.Errorf("%d: errors.Is(err, %v) = %v, want %v", tt.status, target, got, !got)
+			}
+		}
+	}
+}
+
+func TestCallReturnsAPIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service ID is in use by a static service.", http.StatusConflict)
+	}))
+	defer ts.Close()
+
+	_, err := New(ts.URL).Add(context.Background(), api.RegisterRequest{ID: "1", Name: "web", PortNo: 80})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.StatusCode != http.StatusConflict || ae.Message != "Service ID is in use by a static service." {
+		t.Fatalf("Add() error = %v, want the conflict", err)
+	}
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Add() error %v is not ErrConflict", err)
+	}
+}
+
+func TestRegisterWithInterval(t *testing.T) {
+	var lock sync.Mutex
+	adds := 0
+	removed := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/service/add":
+			req := api.RegisterRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID != "web1" {
+				http.Error(w, "Invalid request.", http.StatusBadRequest)
+				return
+			}
+			adds++
+			json.NewEncoder(w).Encode(api.RegisterResponse{ID: req.ID})
+		case r.Method == "DELETE" && r.URL.Path == "/service/remove/web1":
+			removed = "web1"
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g, err := New(ts.URL).RegisterWithInterval(ctx, api.RegisterRequest{ID: "web1", Name: "web", PortNo: 80}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The registration is renewed at the interval
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lock.Lock()
+		n := adds
+		lock.Unlock()
+		if n >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("registration was sent %d times, want at least 3", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Cancelling the context deregisters the service
+	cancel()
+	select {
+	case <-g.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("registration was not stopped after the context was cancelled")
+	}
+	if err := g.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if removed != "web1" {
+		t.Error("service was not deregistered after the context was cancelled")
+	}
+}
+
+func TestNewUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zcservice.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets are not supported: ", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/service/list" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(api.ListResponse{Services: []api.RegistrationItem{{ID: "web1"}}})
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	resp, err := New("unix://" + path).List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Services) != 1 || resp.Services[0].ID != "web1" {
+		t.Errorf("List() = %+v, want the service from the socket", resp.Services)
+	}
+}
+
+func TestWatchFiltersServiceType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/service/events" {
+			http.NotFound(w, r)
+			return
+		}
+		enc := json.NewEncoder(w)
+		enc.Encode(api.Event{Type: "registered", ID: "1", ServiceType: "_http._tcp"})
+		enc.Encode(api.Event{Type: "registered", ID: "2", ServiceType: "_ipp._tcp"})
+		enc.Encode(api.Event{Type: "deregistered", ID: "1", ServiceType: "_http._tcp"})
+	}))
+	defer ts.Close()
+
+	events, err := New(ts.URL).Watch(context.Background(), "_http._tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for e := range events {
+		ids = append(ids, e.Type+" "+e.ID)
+	}
+	if len(ids) != 2 || ids[0] != "registered 1" || ids[1] != "deregistered 1" {
+		t.Errorf("Watch() events = %v, want the events for _http._tcp", ids)
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Errors matched by the API errors returned by zcservice, e.g. errors.Is(err, client.ErrConflict)
+var (
+	ErrBadRequest   = errors.New("invalid request")          // The request values are invalid
+	ErrUnauthorized = errors.New("not authorized")           // The bearer token is missing or not allowed
+	ErrForbidden    = errors.New("forbidden")                // The request is not allowed, e.g. removing a static service
+	ErrNotFound     = errors.New("service not found")        // The service is not registered, or could not be found
+	ErrConflict     = errors.New("service ID is in use")     // The service ID is in use by a static service or a service file
+	ErrUnavailable  = errors.New("zcservice is unavailable") // zcservice could not process the request
+)
+
+// APIError is returned when zcservice responds to a request with an error status
+type APIError struct {
+	StatusCode int    // HTTP status code of the response
+	Message    string // Error message returned by zcservice
+}
+
+// newAPIError creates an API error from the error response
+func newAPIError(resp *http.Response) *APIError {
+	b, _ := ioutil.ReadAll(resp.Body)
+	m := strings.TrimSpace(string(b))
+	if m == "" {
+		m = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: m}
+}
+
+// Error returns the error message, prefixed with the status code
+func (e *APIError) Error() string {
+	return fmt.Sprintf("zcservice: %d %s", e.StatusCode, e.Message)
+}
+
+// Is returns whether the status code matches the specified error
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	}
+	return e.StatusCode >= 500 && target == ErrUnavailable
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// DefaultRenewInterval is the interval between renewals of a registration if none is specified
+const DefaultRenewInterval = 30 * time.Second
+
+// deregisterTimeout is the maximum time spent deregistering a service after its context is cancelled
+const deregisterTimeout = 5 * time.Second
+
+// Registration is a service registered by Register.  It is renewed until its context is cancelled.
+type Registration struct {
+	ID       string              // ID of the service registration
+	Interval time.Duration       // Interval between renewals
+	client   *Client             // Client used to renew and deregister the service
+	req      api.RegisterRequest // Request sent to renew the registration
+	done     chan struct{}       // Closed once the service is deregistered
+	err      error               // Error from the last renewal, or from deregistering the service
+	lock     sync.Mutex          // Mutex lock for err
+}
+
+// Register registers the service and keeps renewing the registration every DefaultRenewInterval,
+// so that it is restored if zcservice is restarted.  The service is deregistered when the context
+// is cancelled.  If the request has no ID, a new ID is generated.
+func (c *Client) Register(ctx context.Context, r api.RegisterRequest) (*Registration, error) {
+	return c.RegisterWithInterval(ctx, r, DefaultRenewInterval)
+}
+
+// RegisterWithInterval registers the service like Register, renewing the registration at the specified interval
+func (c *Client) RegisterWithInterval(ctx context.Context, r api.RegisterRequest, interval time.Duration) (*Registration, error) {
+	if interval <= 0 {
+		interval = DefaultRenewInterval
+	}
+	resp, err := c.Add(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	r.ID = resp.ID
+	g := &Registration{
+		ID:       r.ID,
+		Interval: interval,
+		client:   c,
+		req:      r,
+		done:     make(chan struct{}),
+	}
+	go g.run(ctx)
+	return g, nil
+}
+
+// Add registers the service once, without renewing the registration.  The service stays registered
+// until it is deregistered, or the process being watched exits.  If the request has no ID, a new ID
+// is generated.
+func (c *Client) Add(ctx context.Context, r api.RegisterRequest) (*api.RegisterResponse, error) {
+	if r.ID == "" {
+		if id, err := uuid.NewV4(); err == nil {
+			r.ID = strings.Replace(id.String(), "-", "", -1)
+		}
+	}
+	resp := api.RegisterResponse{}
+	if err := c.call(ctx, "POST", "/service/add", &r, &resp); err != nil {
+		return nil, err
+	}
+	if resp.ID == "" {
+		resp.ID = r.ID
+	}
+	return &resp, nil
+}
+
+// Done returns a channel that is closed once the service has been deregistered
+func (g *Registration) Done() <-chan struct{} {
+	return g.done
+}
+
+// Err returns the error from the last renewal while the registration is running, or the error from
+// deregistering the service once Done is closed.  Returns nil if there was no error.
+func (g *Registration) Err() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.err
+}
+
+// run renews the registration until the context is cancelled, then deregisters the service
+func (g *Registration) run(ctx context.Context) {
+	defer close(g.done)
+	t := time.NewTicker(g.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// The context is already cancelled, so deregister with a new one
+			dctx, cancel := context.WithTimeout(context.Background(), deregisterTimeout)
+			defer cancel()
+			g.setErr(g.client.Deregister(dctx, g.ID))
+			return
+		case <-t.C:
+			g.setErr(g.client.call(ctx, "POST", "/service/add", &g.req, nil))
+		}
+	}
+}
+
+// setErr sets the error of the registration
+func (g *Registration) setErr(err error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.err = err
+}
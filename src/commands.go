@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/client"
+	"github.com/Brumawen/zcservice/src/discovery"
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+// Exit codes returned by the commands
+const (
+	exitOK          = 0 // The command succeeded
+	exitError       = 1 // The command failed
+	exitUsage       = 2 // The command line is invalid
+	exitNotFound    = 3 // The service was not found
+	exitUnavailable = 4 // The zcservice daemon could not be reached
+)
+
+// command is a command line subcommand
+type command struct {
+	Usage string                                 // Arguments of the command
+	Help  string                                 // Description of the command
+	Run   func(o *cmdOptions, args []string) int // Runs the command and returns the exit code
+	Flags func(fs *flag.FlagSet, o *cmdOptions)  // Adds the command specific flags, if any
+}
+
+// commands are the subcommands of zcservice, by name
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"browse":   {Usage: "<type>", Help: "Search the network for services of a type", Run: runBrowse, Flags: browseFlags},
+		"lookup":   {Usage: "<type> <instance>", Help: "Search the network for a service instance", Run: runLookup, Flags: browseFlags},
+		"register": {Usage: "-name <name> -port <port> [-type <type>] [-txt k=v]...", Help: "Register a service", Run: runRegister, Flags: registerFlags},
+		"list":     {Help: "List the services registered with the daemon", Run: runList},
+		"remove":   {Usage: "<id>", Help: "Deregister a service registered with the daemon", Run: runRemove},
+		"watch":    {Usage: "<type>", Help: "Watch the network for services of a type coming and going", Run: runWatch, Flags: watchFlags},
+		"exec":     {Usage: "-name <name> -port <port> [-type <type>] [-ready <addr>] -- <program> [arguments]", Help: "Run a program and register it as a service while it runs", Run: runExec, Flags: execFlags},
+	}
+}
+
+// cmdOptions holds the flags shared by the commands
+type cmdOptions struct {
+	Name       string         // Name of the command
+	Addr       string         // Address of the zcservice daemon
+	Token      string         // Bearer token sent to the daemon
+	Output     string         // Output format, either "table" or "json"
+	Standalone bool           // Use zeroconf directly instead of the daemon
+	Wait       int            // Duration in secs to wait for responses when discovering services
+	Domain     string         // Domain to search
+	Healthy    bool           // Only include healthy services
+	Interval   int            // Interval in secs between searches when watching
+	Register   registerValues // Values of the register and exec commands
+	Exec       execValues     // Values of the exec command
+	Out        io.Writer      // Standard output
+	Err        io.Writer      // Standard error
+	usage      func()         // Prints the usage of the command
+}
+
+// registerValues holds the flags of the register command
+type registerValues struct {
+	ID       string   // ID of the service
+	Name     string   // Name of the service
+	Port     int      // Port number of the service
+	Type     string   // Service type
+	Subtypes listFlag // Subtypes of the service
+	Text     listFlag // Text entries of the service
+	PID      int      // Process ID to watch
+}
+
+// listFlag is a flag that can be given more than once
+type listFlag []string
+
+// String returns the flag values
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds a flag value
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// isCommand returns whether the argument is the name of a subcommand
+func isCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help"
+}
+
+// runCommand runs the named subcommand with the arguments and returns the exit code
+func runCommand(name string, args []string) int {
+	if name == "help" {
+		printCommands(os.Stdout)
+		return exitOK
+	}
+	cmd := commands[name]
+	o := &cmdOptions{Name: name, Out: os.Stdout, Err: os.Stderr}
+	fs := flag.NewFlagSet("zcservice "+name, flag.ContinueOnError)
+	fs.SetOutput(o.Err)
+	fs.StringVar(&o.Addr, "addr", os.Getenv("ZCSERVICE_ADDR"), "Address of the zcservice daemon, e.g. \"unix:///run/zcservice.sock\".  Defaults to \""+client.DefaultAddress+"\".  Can also be set with the ZCSERVICE_ADDR environment variable.")
+	fs.StringVar(&o.Token, "token", os.Getenv("ZCSERVICE_TOKEN"), "Bearer token sent to the daemon.  Can also be set with the ZCSERVICE_TOKEN environment variable.")
+	fs.StringVar(&o.Output, "o", "table", "Output format, either 'table' or 'json'.")
+	fs.BoolVar(&o.Standalone, "standalone", false, "Use zeroconf directly instead of a running daemon.")
+	if cmd.Flags != nil {
+		cmd.Flags(fs, o)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(o.Err, "Usage: zcservice %s [flags] %s\n\n%s.\n\nFlags:\n", name, cmd.Usage, cmd.Help)
+		fs.PrintDefaults()
+	}
+	o.usage = fs.Usage
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if o.Output != "table" && o.Output != "json" {
+		fmt.Fprintf(o.Err, "zcservice %s: invalid output format '%s'\n", name, o.Output)
+		return exitUsage
+	}
+	return cmd.Run(o, fs.Args())
+}
+
+// printCommands prints the list of subcommands
+func printCommands(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Usage: zcservice <command> [flags] [arguments]")
+	fmt.Fprintln(w, "       zcservice [-p port] [-config path] [-service action]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, n := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", n, commands[n].Help)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun 'zcservice <command> -h' for the flags of a command.")
+}
+
+// browseFlags adds the flags of the browse and lookup commands
+func browseFlags(fs *flag.FlagSet, o *cmdOptions) {
+	fs.IntVar(&o.Wait, "wait", discovery.DefaultWaitTime, "Duration in secs to wait for responses.")
+	fs.StringVar(&o.Domain, "domain", "", "Domain to search.  Defaults to \"local\".")
+	fs.BoolVar(&o.Healthy, "healthy", false, "Only include services that are healthy, or do not publish their health.")
+}
+
+// watchFlags adds the flags of the watch command
+func watchFlags(fs *flag.FlagSet, o *cmdOptions) {
+	browseFlags(fs, o)
+	fs.IntVar(&o.Interval, "interval", int(discovery.DefaultWatchInterval.Seconds()), "Interval in secs between searches.")
+}
+
+// registerFlags adds the flags of the register command
+func registerFlags(fs *flag.FlagSet, o *cmdOptions) {
+	fs.StringVar(&o.Register.ID, "id", "", "ID of the service.  Generated if blank.")
+	fs.StringVar(&o.Register.Name, "name", "", "Name of the service.")
+	fs.IntVar(&o.Register.Port, "port", 0, "Port number of the service.")
+	fs.StringVar(&o.Register.Type, "type", "", "Service type, e.g. \"_http._tcp\".  Defaults to the default service type of the daemon.")
+	fs.Var(&o.Register.Subtypes, "subtype", "Subtype of the service, e.g. \"_printer\".  Can be given more than once.")
+	fs.Var(&o.Register.Text, "txt", "Text entry of the service, in key=value format.  Can be given more than once.")
+	fs.IntVar(&o.Register.PID, "pid", 0, "Process ID to watch.  The service is deregistered when the process exits.")
+}
+
+// runBrowse searches the network for services of a type
+func runBrowse(o *cmdOptions, args []string) int {
+	if len(args) != 1 {
+		o.usage()
+		return exitUsage
+	}
+	resp, err := o.browse(args[0])
+	if err != nil {
+		return o.fail(err)
+	}
+	sort.Slice(resp.Services, func(i, j int) bool { return resp.Services[i].Name < resp.Services[j].Name })
+	if o.Output == "json" {
+		return o.printJSON(resp)
+	}
+	o.printServices(resp.Services)
+	return exitOK
+}
+
+// runLookup searches the network for a service instance
+func runLookup(o *cmdOptions, args []string) int {
+	if len(args) != 2 {
+		o.usage()
+		return exitUsage
+	}
+	resp, err := o.browse(args[0])
+	if err != nil {
+		return o.fail(err)
+	}
+	i := resp.Find(args[1])
+	if i == nil {
+		return o.fail(client.ErrNotFound)
+	}
+	if o.Output == "json" {
+		return o.printJSON(i)
+	}
+	o.printServices([]api.ServiceItem{*i})
+	return exitOK
+}
+
+// runRegister registers a service.  The daemon keeps the service registered after the command
+// exits.  A standalone service is registered until the command is interrupted.
+func runRegister(o *cmdOptions, args []string) int {
+	if len(args) != 0 {
+		o.usage()
+		return exitUsage
+	}
+	req, code := o.registerRequest()
+	if code != exitOK {
+		return code
+	}
+	if !o.Standalone {
+		resp, err := o.client().Add(context.Background(), req)
+		if err != nil {
+			return o.fail(err)
+		}
+		return o.printID(resp)
+	}
+
+	reg := registry.New()
+	reg.Log = func(c string) *slog.Logger {
+		return slog.New(slog.NewTextHandler(o.Err, &slog.HandlerOptions{Level: slog.LevelWarn})).With(LogComponent, c)
+	}
+	events := reg.Subscribe()
+	resp := reg.Register(&req, api.SourceAPI, registry.Caller{})
+	if code := o.printID(&resp); code != exitOK {
+		reg.Close()
+		return code
+	}
+
+	// Stay registered until interrupted, or the process being watched exits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			reg.Close()
+			return exitOK
+		case e := <-events:
+			if e.ID == resp.ID && e.Type == api.EventDeregistered {
+				return exitOK
+			}
+		}
+	}
+}
+
+// runList lists the services registered with the daemon
+func runList(o *cmdOptions, args []string) int {
+	if len(args) != 0 {
+		o.usage()
+		return exitUsage
+	}
+	if o.Standalone {
+		return o.needsDaemon()
+	}
+	resp, err := o.client().List(context.Background())
+	if err != nil {
+		return o.fail(err)
+	}
+	if o.Output == "json" {
+		return o.printJSON(resp)
+	}
+	tw := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tPORT\tSOURCE\tHEALTH\tANNOUNCED")
+	for _, i := range resp.Services {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%t\n", i.ID, i.Name, i.ServiceType, i.PortNo, i.Source, i.Health, i.Announced)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// runRemove deregisters a service registered with the daemon
+func runRemove(o *cmdOptions, args []string) int {
+	if len(args) != 1 {
+		o.usage()
+		return exitUsage
+	}
+	if o.Standalone {
+		return o.needsDaemon()
+	}
+	c := o.client()
+	resp, err := c.List(context.Background())
+	if err != nil {
+		return o.fail(err)
+	}
+	found := false
+	for _, i := range resp.Services {
+		found = found || i.ID == args[0]
+	}
+	if !found {
+		return o.fail(client.ErrNotFound)
+	}
+	if err := c.Deregister(context.Background(), args[0]); err != nil {
+		return o.fail(err)
+	}
+	return exitOK
+}
+
+// runWatch watches the network for services of a type coming and going, until interrupted
+func runWatch(o *cmdOptions, args []string) int {
+	if len(args) != 1 {
+		o.usage()
+		return exitUsage
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r := api.GetRequest{ServiceType: args[0], Domain: o.Domain, WaitTime: o.Wait, OnlyHealthy: o.Healthy}
+	interval := time.Duration(o.Interval) * time.Second
+	var events <-chan api.ServiceEvent
+	var err error
+	if o.Standalone {
+		events, err = discovery.Watch(ctx, r, interval)
+	} else {
+		events, err = o.client().WatchServices(ctx, r, interval)
+	}
+	if err != nil {
+		return o.fail(err)
+	}
+	enc := json.NewEncoder(o.Out)
+	for e := range events {
+		if o.Output == "json" {
+			enc.Encode(e)
+			continue
+		}
+		i := e.Service
+		fmt.Fprintf(o.Out, "%s  %-7s  %s  %s:%d  %s\n", e.Time.Format("15:04:05"), e.Type, i.Name, i.HostName, i.Port, addresses(i))
+	}
+	if ctx.Err() != nil {
+		return exitOK
+	}
+	// The events stopped without being interrupted, so the connection to the daemon was lost
+	fmt.Fprintf(o.Err, "zcservice %s: connection to zcservice was lost\n", o.Name)
+	return exitUnavailable
+}
+
+// browse searches the network for services of the type, using the daemon unless running standalone
+func (o *cmdOptions) browse(serviceType string) (*api.GetResponse, error) {
+	r := api.GetRequest{ServiceType: serviceType, Domain: o.Domain, WaitTime: o.Wait, OnlyHealthy: o.Healthy}
+	if o.Standalone {
+		resp, err := discovery.Browse(context.Background(), r)
+		return &resp, err
+	}
+	return o.client().Browse(context.Background(), r)
+}
+
+// registerRequest returns the request to register the service described by the register flags
+func (o *cmdOptions) registerRequest() (api.RegisterRequest, int) {
+	v := o.Register
+	if v.Name == "" || v.Port <= 0 || v.Port > 65535 {
+		o.usage()
+		return api.RegisterRequest{}, exitUsage
+	}
+	for _, t := range v.Text {
+		if api.TextKey(t) == "" {
+			fmt.Fprintf(o.Err, "zcservice %s: invalid text entry '%s'\n", o.Name, t)
+			return api.RegisterRequest{}, exitUsage
+		}
+	}
+	return api.RegisterRequest{
+		ID:          v.ID,
+		Name:        v.Name,
+		PortNo:      v.Port,
+		ServiceType: v.Type,
+		Subtypes:    v.Subtypes,
+		Text:        v.Text,
+		PID:         v.PID,
+	}, exitOK
+}
+
+// client returns a client for the daemon
+func (o *cmdOptions) client() *client.Client {
+	c := client.New(o.Addr)
+	c.Token = o.Token
+	return c
+}
+
+// printServices prints the services as a table
+func (o *cmdOptions) printServices(l []api.ServiceItem) {
+	tw := tabwriter.NewWriter(o.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tHOST\tPORT\tADDRESSES\tTEXT")
+	for _, i := range l {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", i.Name, i.HostName, i.Port, addresses(i), strings.Join(i.Text, " "))
+	}
+	tw.Flush()
+}
+
+// printID prints the ID of a registered service
+func (o *cmdOptions) printID(resp *api.RegisterResponse) int {
+	if o.Output == "json" {
+		return o.printJSON(resp)
+	}
+	fmt.Fprintln(o.Out, resp.ID)
+	return exitOK
+}
+
+// printJSON prints the value as indented json
+func (o *cmdOptions) printJSON(v interface{}) int {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return o.fail(err)
+	}
+	fmt.Fprintln(o.Out, string(b))
+	return exitOK
+}
+
+// needsDaemon reports that the command cannot run standalone
+func (o *cmdOptions) needsDaemon() int {
+	fmt.Fprintf(o.Err, "zcservice %s: the command needs a running daemon\n", o.Name)
+	return exitUsage
+}
+
+// fail prints the error and returns the exit code for it
+func (o *cmdOptions) fail(err error) int {
+	fmt.Fprintf(o.Err, "zcservice %s: %s\n", o.Name, err)
+	var ne *net.OpError
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return exitNotFound
+	case errors.As(err, &ne):
+		return exitUnavailable
+	}
+	return exitError
+}
+
+// addresses returns the IP addresses of the service, separated by commas
+func addresses(i api.ServiceItem) string {
+	l := []string{}
+	for _, ip := range append(i.AddrIPv4, i.AddrIPv6...) {
+		l = append(l, ip.String())
+	}
+	return strings.Join(l, ",")
+}
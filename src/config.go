@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	uuid "github.com/satori/go.uuid"
@@ -13,31 +16,156 @@ import (
 
 // Config defines the configuration for the web server
 type Config struct {
-	ID                 string `json:"id"`                 // ID of the ZeroConf microservice
-	Name               string `json:"name"`               // Name of the service
-	DefaultServiceType string `json:"defaultServiceType"` // Default Service Type to use
+	ID                 string              `json:"id"`                    // ID of the ZeroConf microservice
+	Name               string              `json:"name"`                  // Name of the service
+	DefaultServiceType string              `json:"defaultServiceType"`    // Default Service Type to use
+	Listeners          []ListenerConfig    `json:"listeners,omitempty"`   // Addresses the web server listens on.  If empty, only the loopback address is used
+	GRPC               []ListenerConfig    `json:"grpc,omitempty"`        // Addresses the gRPC interface listens on.  If empty, the gRPC interface is not served
+	AllowScriptChecks  bool                `json:"allowScriptChecks"`     // Indicates whether registrations may use script health checks
+	LogLevel           string              `json:"logLevel,omitempty"`    // Log level, either "debug", "info", "warn" or "error".  Defaults to "info", or "debug" when running interactively
+	LogFormat          string              `json:"logFormat,omitempty"`   // Log output format, either "text" or "json".  Defaults to "text"
+	Audit              AuditConfig         `json:"audit"`                 // Audit log settings
+	Proxy              ProxyConfig         `json:"proxy"`                 // Settings for forwarding requests to discovered services
+	Consul             ConsulConfig        `json:"consul"`                // Settings for the Consul compatible web methods
+	Prometheus         PrometheusConfig    `json:"prometheus"`            // Prometheus service discovery settings
+	Services           []ServiceDefinition `json:"services,omitempty"`    // Static services announced for as long as they are in the configuration
+	ServicesDir        string              `json:"servicesDir,omitempty"` // Folder of service definition files.  Defaults to "services.d" in the configuration folder
+	path               string              // Path of the file the configuration was read from
 }
 
-// ReadFromFile will read the configuration settings from the specified file
-func (c *Config) ReadFromFile(path string) error {
-	_, err := os.Stat(path)
-	if !os.IsNotExist(err) {
-		b, err := ioutil.ReadFile(path)
-		if err == nil {
-			err = json.Unmarshal(b, &c)
+// Environment variables that override the values in the configuration file
+var configEnv = map[string]func(c *Config, v string){
+	"ZCSERVICE_ID":                   func(c *Config, v string) { c.ID = v },
+	"ZCSERVICE_NAME":                 func(c *Config, v string) { c.Name = v },
+	"ZCSERVICE_DEFAULT_SERVICE_TYPE": func(c *Config, v string) { c.DefaultServiceType = v },
+	"ZCSERVICE_ALLOW_SCRIPT_CHECKS":  func(c *Config, v string) { c.AllowScriptChecks, _ = strconv.ParseBool(v) },
+	"ZCSERVICE_LOG_LEVEL":            func(c *Config, v string) { c.LogLevel = v },
+	"ZCSERVICE_LOG_FORMAT":           func(c *Config, v string) { c.LogFormat = v },
+	"ZCSERVICE_AUDIT_FILE":           func(c *Config, v string) { c.Audit.File = v },
+}
+
+// Load reads the configuration settings from the specified file, saving any
+// generated defaults, and then applies the environment variable overrides.
+// The overrides are not saved to the file.
+func (c *Config) Load(path string) error {
+	err := c.ReadFromFile(path)
+	c.ApplyEnv()
+	return err
+}
+
+// ApplyEnv overrides the configuration values with any ZCSERVICE_* environment variables that are set
+func (c *Config) ApplyEnv() {
+	for k, f := range configEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			f(c, v)
 		}
 	}
+}
+
+// ReadFromFile will read the configuration settings from the specified file.
+// The format of the file is chosen by its extension, and the file is checked
+// against the configuration schema.  If there are errors, the valid settings
+// are still read and the errors are returned as ConfigErrors.
+func (c *Config) ReadFromFile(path string) error {
+	c.path = path
+	d, err := readConfigDoc(path)
+	if os.IsNotExist(err) {
+		c.SetDefaults()
+		return nil
+	}
+	if err != nil {
+		c.setDefaults()
+		return err
+	}
+	errs := configSchema.Validate(d)
+	if err := d.decode(c); err != nil && len(errs) == 0 {
+		errs = append(errs, &ConfigError{Message: err.Error()})
+	}
+	if len(errs) != 0 {
+		// Don't save the generated defaults over a file with errors in it
+		c.setDefaults()
+		errs.sort()
+		return errs
+	}
 	c.SetDefaults()
-	return err
+	return nil
+}
+
+// CheckConfigFile reads the specified configuration file and returns every error
+// found in it as ConfigErrors, with the line number of each error where it is known
+func CheckConfigFile(path string) error {
+	d, err := readConfigDoc(path)
+	if err != nil {
+		return err
+	}
+	errs := configSchema.Validate(d)
+	c := &Config{}
+	d.decode(c)
+	c.setDefaults()
+	for _, e := range c.validate() {
+		if !errs.covers(e.Path) {
+			e.Line = d.line(e.Path)
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	errs.sort()
+	return errs
 }
 
-// WriteToFile will write the configuration settings to the specified file
+// WriteToFile will write the configuration settings to the specified file, in the
+// format chosen by its extension.  The file is replaced atomically so that a partially
+// written file is never read.  A new file is only readable by its owner, as it may hold
+// listener tokens.
 func (c *Config) WriteToFile(path string) error {
-	b, err := json.Marshal(c)
+	b, err := marshalConfig(c, ConfigFormat(path))
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path, b, 0666)
+	return writeFileAtomic(path, b, 0600)
+}
+
+// saveDefaults saves the generated id and defaultServiceType to the configuration file.
+// YAML and TOML files are usually written by hand, so any of these settings that are
+// missing are added to the top of the file to keep its comments and layout.  The whole
+// file is only written if it is JSON, or if the settings are in the file but blank.
+func (c *Config) saveDefaults() error {
+	format := ConfigFormat(c.path)
+	b, err := ioutil.ReadFile(c.path)
+	if err != nil || format == ConfigJSON || bytes.HasPrefix(bytes.TrimSpace(b), []byte("%")) || bytes.HasPrefix(bytes.TrimSpace(b), []byte("---")) {
+		return c.WriteToFile(c.path)
+	}
+	d, err := parseConfigDoc(b, format)
+	if err != nil {
+		return err
+	}
+	m, _ := d.Value.(map[string]interface{})
+	sep := ": "
+	if format == ConfigTOML {
+		sep = " = "
+	}
+	h := ""
+	for _, k := range []string{"id", "defaultServiceType"} {
+		if _, ok := d.lines[k]; ok {
+			if v, _ := m[k].(string); v == "" {
+				return c.WriteToFile(c.path)
+			}
+			continue
+		}
+		v := c.ID
+		if k == "defaultServiceType" {
+			v = c.DefaultServiceType
+		}
+		h += k + sep + strconv.Quote(v) + "\n"
+	}
+	return writeFileAtomic(c.path, append([]byte(h), b...), 0600)
+}
+
+// Path returns the path of the file the configuration was read from
+func (c *Config) Path() string {
+	return c.path
 }
 
 // ReadFrom reads the string from the reader and deserializes it into the entity values
@@ -63,8 +191,89 @@ func (c *Config) WriteTo(w http.ResponseWriter) error {
 	return nil
 }
 
-// SetDefaults checks the values and sets the defaults
+// Validate checks the configuration values for errors
+func (c *Config) Validate() error {
+	if errs := c.validate(); len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// validate checks the configuration values, returning every error found
+func (c *Config) validate() ConfigErrors {
+	errs := ConfigErrors{}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, &ConfigError{Path: "logLevel", Message: err.Error()})
+	}
+	if c.LogFormat != "" && c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, &ConfigError{Path: "logFormat", Message: fmt.Sprintf("invalid log format '%s'", c.LogFormat)})
+	}
+	for i := range c.Listeners {
+		if err := c.Listeners[i].Validate(); err != nil {
+			errs = append(errs, &ConfigError{Path: fmt.Sprintf("listeners[%d]", i), Message: err.Error()})
+		}
+	}
+	for i := range c.GRPC {
+		if err := c.GRPC[i].Validate(); err != nil {
+			errs = append(errs, &ConfigError{Path: fmt.Sprintf("grpc[%d]", i), Message: err.Error()})
+		}
+	}
+	if err := c.Proxy.Validate(); err != nil {
+		errs = append(errs, &ConfigError{Path: "proxy", Message: err.Error()})
+	}
+	if err := c.Consul.Validate(); err != nil {
+		errs = append(errs, &ConfigError{Path: "consul", Message: err.Error()})
+	}
+	for i := range c.Prometheus.Files {
+		if err := c.Prometheus.Files[i].Validate(); err != nil {
+			errs = append(errs, &ConfigError{Path: fmt.Sprintf("prometheus.files[%d]", i), Message: err.Error()})
+		}
+	}
+	ids := map[string]bool{}
+	for i := range c.Services {
+		if err := c.Services[i].Validate(); err != nil {
+			errs = append(errs, &ConfigError{Path: fmt.Sprintf("services[%d]", i), Message: err.Error()})
+		} else if ids[c.Services[i].ID] {
+			errs = append(errs, &ConfigError{Path: fmt.Sprintf("services[%d]", i), Message: fmt.Sprintf("duplicate service id '%s'", c.Services[i].ID)})
+		}
+		ids[c.Services[i].ID] = true
+	}
+	return errs
+}
+
+// Redacted returns a copy of the configuration with secrets removed, suitable for logging
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Listeners = redactListeners(c.Listeners)
+	r.GRPC = redactListeners(c.GRPC)
+	return &r
+}
+
+// redactListeners returns a copy of the listener configurations with their tokens removed
+func redactListeners(lc []ListenerConfig) []ListenerConfig {
+	if lc == nil {
+		return nil
+	}
+	r := make([]ListenerConfig, len(lc))
+	for i, l := range lc {
+		if len(l.Auth.Tokens) != 0 {
+			l.Auth.Tokens = []string{"REDACTED"}
+		}
+		r[i] = l
+	}
+	return r
+}
+
+// SetDefaults checks the values and sets the defaults, saving any generated values
+// to the file the configuration was read from
 func (c *Config) SetDefaults() {
+	if c.setDefaults() && c.path != "" {
+		c.saveDefaults()
+	}
+}
+
+// setDefaults checks the values and sets the defaults, returning whether any generated values need to be saved
+func (c *Config) setDefaults() bool {
 	mustSave := false
 	if c.ID == "" {
 		// Generate a new GUID for this ID
@@ -80,8 +289,8 @@ func (c *Config) SetDefaults() {
 		c.DefaultServiceType = "_zcservice._tcp"
 		mustSave = true
 	}
-	// Todo
-	if mustSave {
-		c.WriteToFile("config.json")
+	for i := range c.Services {
+		c.Services[i].SetDefaults()
 	}
+	return mustSave
 }
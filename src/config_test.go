@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeTestFile writes the content to the named file in a temporary folder and returns its path
+func writeTestFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestConfigFormat(t *testing.T) {
+	tests := []struct {
+		path   string
+		format string
+	}{
+		{"config.json", ConfigJSON},
+		{"config.yaml", ConfigYAML},
+		{"config.YML", ConfigYAML},
+		{"/etc/zcservice/config.toml", ConfigTOML},
+		{"config", ConfigJSON},
+	}
+	for _, tt := range tests {
+		if got := ConfigFormat(tt.path); got != tt.format {
+			t.Errorf("ConfigFormat(%q) = %q, want %q", tt.path, got, tt.format)
+		}
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	type configErr struct {
+		line int
+		path string
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		errs    []configErr
+	}{
+		{"valid JSON", "config.json", `{
+  "id": "abc",
+  "logLevel": "debug",
+  "listeners": [{ "address": "127.0.0.1:20404" }]
+}`, nil},
+		{"valid YAML", "config.yaml", `id: abc
+logLevel: debug
+listeners:
+  - address: 127.0.0.1:20404
+`, nil},
+		{"valid TOML", "config.toml", `id = "abc"
+logLevel = "debug"
+
+[[listeners]]
+address = "127.0.0.1:20404"
+`, nil},
+		{"empty file", "config.yaml", "", nil},
+		{"JSON unknown setting", "config.json", `{
+  "id": "abc",
+  "bogus": true
+}`, []configErr{{3, "bogus"}}},
+		{"JSON wrong type", "config.json", `{
+  "id": "abc",
+
+  "allowScriptChecks": "yes"
+}`, []configErr{{4, "allowScriptChecks"}}},
+		{"JSON syntax error", "config.json", `{
+  "id": "abc",
+  "name": 
+}`, []configErr{{4, ""}}},
+		{"JSON nested error", "config.json", `{
+  "listeners": [
+    { "address": "127.0.0.1:1" },
+    {
+      "address": "/run/zc.sock",
+      "network": "udp"
+    }
+  ]
+}`, []configErr{{6, "listeners[1].network"}}},
+		{"YAML nested error", "config.yaml", `id: abc
+listeners:
+  - address: 127.0.0.1:1
+    network: udp
+`, []configErr{{4, "listeners[0].network"}}},
+		{"YAML syntax error", "config.yaml", `id: abc
+listeners:
+  - address: [
+`, []configErr{{3, ""}}},
+		{"TOML unknown setting", "config.toml", `id = "abc"
+
+bogus = 1
+`, []configErr{{3, "bogus"}}},
+		{"TOML table error", "config.toml", `id = "abc"
+
+[[listeners]]
+address = "127.0.0.1:1"
+network = "udp"
+`, []configErr{{5, "listeners[0].network"}}},
+		{"errors sorted by line", "config.yaml", `id: abc
+bogus: 1
+logFormat: xml
+other: 2
+`, []configErr{{2, "bogus"}, {3, "logFormat"}, {4, "other"}}},
+		{"invalid static service", "config.json", `{
+  "services": [
+    {
+      "name": "web",
+      "portNo": 70000
+    }
+  ]
+}`, []configErr{{5, "services[0].portNo"}}},
+	}
+	for _, tt := range tests {
+		err := CheckConfigFile(writeTestFile(t, tt.file, tt.content))
+		if tt.errs == nil {
+			if err != nil {
+				t.Errorf("%s: CheckConfigFile() error = %v", tt.name, err)
+			}
+			continue
+		}
+		var errs ConfigErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: CheckConfigFile() error = %v, want ConfigErrors", tt.name, err)
+			continue
+		}
+		if len(errs) != len(tt.errs) {
+			t.Errorf("%s: CheckConfigFile() error = %v, want %d errors", tt.name, err, len(tt.errs))
+			continue
+		}
+		for i, e := range errs {
+			if e.Line != tt.errs[i].line || (tt.errs[i].path != "" && e.Path != tt.errs[i].path) {
+				t.Errorf("%s: error %d = line %d %q (%v), want line %d %q", tt.name, i, e.Line, e.Path, e, tt.errs[i].line, tt.errs[i].path)
+			}
+		}
+	}
+}
+
+func TestConfigReadFromFile(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"config.json", `{"id": "abc", "name": "Test", "defaultServiceType": "_test._tcp", "allowScriptChecks": true}`},
+		{"config.yaml", "id: abc\nname: Test\ndefaultServiceType: _test._tcp\nallowScriptChecks: true\n"},
+		{"config.toml", "id = \"abc\"\nname = \"Test\"\ndefaultServiceType = \"_test._tcp\"\nallowScriptChecks = true\n"},
+	}
+	for _, tt := range tests {
+		c := &Config{}
+		if err := c.ReadFromFile(writeTestFile(t, tt.file, tt.content)); err != nil {
+			t.Errorf("%s: ReadFromFile() error = %v", tt.file, err)
+			continue
+		}
+		if c.ID != "abc" || c.Name != "Test" || c.DefaultServiceType != "_test._tcp" || !c.AllowScriptChecks {
+			t.Errorf("%s: ReadFromFile() = %+v", tt.file, c)
+		}
+	}
+}
+
+func TestConfigSaveDefaults(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"config.json", `{"name": "Test"}`},
+		{"config.yaml", "# Comment\nname: Test\n"},
+		{"config.toml", "# Comment\nname = \"Test\"\n"},
+	}
+	for _, tt := range tests {
+		p := writeTestFile(t, tt.file, tt.content)
+		c := &Config{}
+		if err := c.ReadFromFile(p); err != nil {
+			t.Fatalf("%s: ReadFromFile() error = %v", tt.file, err)
+		}
+		if c.ID == "" || c.DefaultServiceType == "" {
+			t.Fatalf("%s: defaults not set: %+v", tt.file, c)
+		}
+
+		// The generated values are saved, so reading the file again gives the same values
+		r := &Config{}
+		if err := r.ReadFromFile(p); err != nil {
+			t.Fatalf("%s: ReadFromFile() after save error = %v", tt.file, err)
+		}
+		if r.ID != c.ID || r.Name != "Test" || r.DefaultServiceType != c.DefaultServiceType {
+			t.Errorf("%s: saved configuration = %+v, want id %q", tt.file, r, c.ID)
+		}
+		if err := CheckConfigFile(p); err != nil {
+			t.Errorf("%s: saved file has errors: %v", tt.file, err)
+		}
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		existing os.FileMode
+		perm     os.FileMode
+		want     os.FileMode
+	}{
+		{"new file", 0, 0600, 0600},
+		{"new world readable file", 0, 0644, 0644},
+		{"existing file keeps its mode", 0640, 0600, 0640},
+		{"existing private file stays private", 0600, 0644, 0600},
+	}
+	for _, tt := range tests {
+		p := filepath.Join(dir, tt.name+".json")
+		if tt.existing != 0 {
+			if err := os.WriteFile(p, []byte("{}"), tt.existing); err != nil {
+				t.Fatal(err)
+			}
+			// WriteFile is subject to the umask, so set the mode exactly
+			if err := os.Chmod(p, tt.existing); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writeFileAtomic(p, []byte(`{"id": "abc"}`), tt.perm); err != nil {
+			t.Fatalf("%s: writeFileAtomic() error = %v", tt.name, err)
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != tt.want {
+			t.Errorf("%s: mode = %o, want %o", tt.name, fi.Mode().Perm(), tt.want)
+		}
+	}
+}
+
+func TestConfigWriteToFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+	p := filepath.Join(t.TempDir(), "config.json")
+	c := &Config{ID: "abc"}
+	if err := c.WriteToFile(p); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode of new configuration file = %o, want 600", fi.Mode().Perm())
+	}
+}
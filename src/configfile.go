@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file formats.  The format is chosen by the file extension.
+const (
+	ConfigJSON = "json" // JSON, the default
+	ConfigYAML = "yaml" // YAML, for files ending in .yaml or .yml
+	ConfigTOML = "toml" // TOML, for files ending in .toml
+)
+
+// configFileNames are the names the configuration file is looked for under, in order of preference
+var configFileNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// ConfigFormat returns the format of the configuration file, based on its extension
+func ConfigFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfigYAML
+	case ".toml":
+		return ConfigTOML
+	}
+	return ConfigJSON
+}
+
+// configDoc is a configuration file parsed into generic values, along with the
+// line number of each setting so that errors can be reported against the file
+type configDoc struct {
+	Value interface{}    // Parsed value.  Objects are map[string]interface{} and numbers are json.Number
+	lines map[string]int // Line number of each setting, keyed by path
+}
+
+// line returns the line number of the setting at the path, or of its nearest parent if it is not known
+func (d *configDoc) line(path string) int {
+	for {
+		if l, ok := d.lines[path]; ok {
+			return l
+		}
+		if path == "" {
+			return 0
+		}
+		if i := strings.LastIndexAny(path, ".["); i >= 0 {
+			path = path[:i]
+		} else {
+			path = ""
+		}
+	}
+}
+
+// decode decodes the document into the value, e.g. a Config.  Values that do not match the
+// types of the value are skipped, so these must already have been reported by the schema.
+func (d *configDoc) decode(v interface{}) error {
+	b, err := json.Marshal(d.Value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// readConfigDoc reads and parses the configuration file.  Syntax errors are returned as ConfigErrors.
+func readConfigDoc(path string) (*configDoc, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigDoc(b, ConfigFormat(path))
+}
+
+// parseConfigDoc parses the configuration in the specified format.  An empty file is an empty configuration.
+func parseConfigDoc(b []byte, format string) (*configDoc, error) {
+	d := &configDoc{lines: map[string]int{}}
+	if len(bytes.TrimSpace(b)) == 0 {
+		d.Value = map[string]interface{}{}
+		return d, nil
+	}
+	var err error
+	switch format {
+	case ConfigYAML:
+		err = d.parseYAML(b)
+	case ConfigTOML:
+		err = d.parseTOML(b)
+	default:
+		err = d.parseJSON(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.Value = normalizeConfigValue(d.Value)
+	return d, nil
+}
+
+// parseJSON parses a JSON configuration file
+func (d *configDoc) parseJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&d.Value); err != nil {
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			return ConfigErrors{{Line: lineAt(b, se.Offset), Message: se.Error()}}
+		}
+		return ConfigErrors{{Line: lineAt(b, int64(len(b))), Message: err.Error()}}
+	}
+
+	// Walk the tokens again to find the line of each setting
+	dec = json.NewDecoder(bytes.NewReader(b))
+	var walk func(path string) error
+	walk = func(path string) error {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if _, ok := d.lines[path]; !ok {
+			d.lines[path] = lineAt(b, dec.InputOffset())
+		}
+		switch t {
+		case json.Delim('{'):
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				p := joinConfigPath(path, fmt.Sprint(k))
+				d.lines[p] = lineAt(b, dec.InputOffset())
+				if err := walk(p); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	return walk("")
+}
+
+// yamlErrorLine matches the line number in YAML syntax errors
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parseYAML parses a YAML configuration file
+func (d *configDoc) parseYAML(b []byte) error {
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			l, _ := strconv.Atoi(m[1])
+			return ConfigErrors{{Line: l, Message: m[2]}}
+		}
+		return ConfigErrors{{Message: err.Error()}}
+	}
+	if len(n.Content) == 0 {
+		d.Value = map[string]interface{}{}
+		return nil
+	}
+	if err := n.Content[0].Decode(&d.Value); err != nil {
+		return ConfigErrors{{Line: n.Content[0].Line, Message: err.Error()}}
+	}
+	d.yamlLines(n.Content[0], "")
+	return nil
+}
+
+// yamlLines records the line of each setting in the YAML node
+func (d *configDoc) yamlLines(n *yaml.Node, path string) {
+	if _, ok := d.lines[path]; !ok {
+		d.lines[path] = n.Line
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			p := joinConfigPath(path, n.Content[i].Value)
+			d.lines[p] = n.Content[i].Line
+			d.yamlLines(n.Content[i+1], p)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			d.yamlLines(c, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// parseTOML parses a TOML configuration file
+func (d *configDoc) parseTOML(b []byte) error {
+	v := map[string]interface{}{}
+	if err := toml.Unmarshal(b, &v); err != nil {
+		var de *toml.DecodeError
+		if errors.As(err, &de) {
+			l, _ := de.Position()
+			return ConfigErrors{{Line: l, Message: de.Error()}}
+		}
+		return ConfigErrors{{Message: err.Error()}}
+	}
+	d.Value = v
+
+	// Parse the file again to find the line of each setting.  The current table
+	// path has the index of any array tables in it, e.g. "listeners[1].auth".
+	p := unstable.Parser{}
+	p.Reset(b)
+	table := ""
+	arrays := map[string]int{}
+	for p.NextExpression() {
+		e := p.Expression()
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			keys, r := tomlKey(e.Key())
+			line := p.Shape(r).Start.Line
+			table = ""
+			for i, k := range keys {
+				table = joinConfigPath(table, k)
+				if i == len(keys)-1 && e.Kind == unstable.ArrayTable {
+					if n, ok := arrays[table]; ok {
+						arrays[table] = n + 1
+					} else {
+						arrays[table] = 0
+						d.lines[table] = line
+					}
+				}
+				if n, ok := arrays[table]; ok {
+					table = fmt.Sprintf("%s[%d]", table, n)
+				}
+			}
+			d.lines[table] = line
+		case unstable.KeyValue:
+			d.tomlLines(&p, table, e)
+		}
+	}
+	return nil
+}
+
+// tomlLines records the line of the TOML key/value, and of the settings in any inline tables it has
+func (d *configDoc) tomlLines(p *unstable.Parser, table string, kv *unstable.Node) {
+	keys, r := tomlKey(kv.Key())
+	path := table
+	for _, k := range keys {
+		path = joinConfigPath(path, k)
+	}
+	line := p.Shape(r).Start.Line
+	d.lines[path] = line
+	d.tomlValueLines(p, path, kv.Value(), line)
+}
+
+// tomlValueLines records the line of the settings in inline tables in the TOML value
+func (d *configDoc) tomlValueLines(p *unstable.Parser, path string, v *unstable.Node, line int) {
+	switch v.Kind {
+	case unstable.InlineTable:
+		it := v.Children()
+		for it.Next() {
+			d.tomlLines(p, path, it.Node())
+		}
+	case unstable.Array:
+		it := v.Children()
+		for i := 0; it.Next(); i++ {
+			ip := fmt.Sprintf("%s[%d]", path, i)
+			d.lines[ip] = line
+			d.tomlValueLines(p, ip, it.Node(), line)
+		}
+	}
+}
+
+// tomlKey returns the parts of a TOML key, and the range of the input it covers
+func tomlKey(it unstable.Iterator) ([]string, unstable.Range) {
+	keys := []string{}
+	r := unstable.Range{}
+	for it.Next() {
+		n := it.Node()
+		if len(keys) == 0 {
+			r = n.Raw
+		}
+		keys = append(keys, string(n.Data))
+	}
+	return keys, r
+}
+
+// normalizeConfigValue converts the values decoded from any of the formats into the
+// values decoded from JSON, so that they can be validated in the same way
+func normalizeConfigValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, string, json.Number:
+		return v
+	case map[string]interface{}:
+		for k, x := range t {
+			t[k] = normalizeConfigValue(x)
+		}
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			m[fmt.Sprint(k)] = normalizeConfigValue(x)
+		}
+		return m
+	case []interface{}:
+		for i, x := range t {
+			t[i] = normalizeConfigValue(x)
+		}
+		return t
+	case int:
+		return json.Number(strconv.Itoa(t))
+	case int64:
+		return json.Number(strconv.FormatInt(t, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(t, 10))
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return fmt.Sprint(t)
+		}
+		return json.Number(strconv.FormatFloat(t, 'g', -1, 64))
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// marshalConfig serializes the configuration in the specified format
+func marshalConfig(c *Config, format string) ([]byte, error) {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil || format == ConfigJSON {
+		return b, err
+	}
+
+	// Convert the JSON into generic values so that the JSON names are used
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v := map[string]interface{}{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	fixConfigNumbers(v)
+	if format == ConfigYAML {
+		return yaml.Marshal(v)
+	}
+	return toml.Marshal(v)
+}
+
+// fixConfigNumbers converts the json.Number values into integers or floats for the YAML and TOML encoders
+func fixConfigNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, x := range t {
+			t[k] = fixConfigNumbers(x)
+		}
+	case []interface{}:
+		for i, x := range t {
+			t[i] = fixConfigNumbers(x)
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
+
+// lineAt returns the line number of the byte offset
+func lineAt(b []byte, offset int64) int {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	return bytes.Count(b[:offset], []byte("\n")) + 1
+}
+
+// writeFileAtomic writes the file so that a partially written file is never read.
+// An existing file keeps its mode, and a new file is created with the given mode.
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
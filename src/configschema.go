@@ -0,0 +1,249 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// configSchemaJSON is the published JSON schema of the configuration file
+//
+//go:embed config.schema.json
+var configSchemaJSON []byte
+
+// configSchema is the parsed configuration file schema
+var configSchema = mustParseSchema(configSchemaJSON)
+
+// Schema defines the subset of JSON schema used to validate the configuration file
+type Schema struct {
+	Description          string             `json:"description,omitempty"`          // Description of the value
+	Type                 string             `json:"type,omitempty"`                 // Type of the value, either "object", "array", "string", "integer", "number" or "boolean"
+	Properties           map[string]*Schema `json:"properties,omitempty"`           // Schemas of the properties of an object
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"` // Whether an object may have properties that are not listed
+	Required             []string           `json:"required,omitempty"`             // Properties an object must have
+	Items                *Schema            `json:"items,omitempty"`                // Schema of the items of an array
+	Enum                 []interface{}      `json:"enum,omitempty"`                 // Values the value is restricted to
+	Pattern              string             `json:"pattern,omitempty"`              // Regular expression a string must match
+	MinLength            *int               `json:"minLength,omitempty"`            // Minimum length of a string
+	Minimum              *float64           `json:"minimum,omitempty"`              // Minimum value of a number
+	Maximum              *float64           `json:"maximum,omitempty"`              // Maximum value of a number
+	pattern              *regexp.Regexp     // Compiled Pattern
+}
+
+// mustParseSchema parses the schema, panicking if it is invalid
+func mustParseSchema(b []byte) *Schema {
+	s := &Schema{}
+	if err := json.Unmarshal(b, s); err != nil {
+		panic("invalid configuration schema: " + err.Error())
+	}
+	s.compile()
+	return s
+}
+
+// compile compiles the patterns of the schema and its children
+func (s *Schema) compile() {
+	if s.Pattern != "" {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	for _, p := range s.Properties {
+		p.compile()
+	}
+	if s.Items != nil {
+		s.Items.compile()
+	}
+}
+
+// Validate checks the value against the schema, returning every error found.
+// The line number of each error is looked up in the document.
+func (s *Schema) Validate(d *configDoc) ConfigErrors {
+	errs := ConfigErrors{}
+	s.validate(d, d.Value, "", &errs)
+	return errs
+}
+
+// validate checks the value at the specified path against the schema
+func (s *Schema) validate(d *configDoc, v interface{}, path string, errs *ConfigErrors) {
+	fail := func(p string, format string, a ...interface{}) {
+		*errs = append(*errs, &ConfigError{Line: d.line(p), Path: p, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if v == nil {
+		// A null value is the same as leaving the setting out
+		return
+	}
+	if s.Type != "" && !isSchemaType(v, s.Type) {
+		fail(path, "expected %s, found %s", s.Type, schemaTypeOf(v))
+		return
+	}
+	if len(s.Enum) != 0 && !inEnum(v, s.Enum) {
+		vals := []string{}
+		for _, e := range s.Enum {
+			if e != "" {
+				vals = append(vals, fmt.Sprintf("%q", e))
+			}
+		}
+		fail(path, "invalid value %s, must be one of %s", formatValue(v), strings.Join(vals, ", "))
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range s.Required {
+			if _, ok := t[k]; !ok {
+				fail(path, "missing required setting '%s'", k)
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := joinConfigPath(path, k)
+			if ps, ok := s.Properties[k]; ok {
+				ps.validate(d, t[k], p, errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				fail(p, "unknown setting '%s'", k)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, x := range t {
+				s.Items.validate(d, x, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		if s.MinLength != nil && len(t) < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail(path, "value must not be blank")
+			} else {
+				fail(path, "value must be at least %d characters", *s.MinLength)
+			}
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			fail(path, "invalid value %q, must match %s", t, s.Pattern)
+		}
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			if s.Minimum != nil && f < *s.Minimum {
+				fail(path, "value %s is less than the minimum of %g", t, *s.Minimum)
+			}
+			if s.Maximum != nil && f > *s.Maximum {
+				fail(path, "value %s is more than the maximum of %g", t, *s.Maximum)
+			}
+		}
+	}
+}
+
+// isSchemaType returns whether the value is of the specified schema type
+func isSchemaType(v interface{}, typ string) bool {
+	switch typ {
+	case "integer":
+		if n, ok := v.(json.Number); ok {
+			_, err := n.Int64()
+			return err == nil
+		}
+		return false
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	}
+	return schemaTypeOf(v) == typ
+}
+
+// schemaTypeOf returns the schema type name of the value
+func schemaTypeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// inEnum returns whether the value is one of the enumerated values.
+// Only string values can be enumerated.
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue formats the value for an error message
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// joinConfigPath returns the path of the named setting within the specified path
+func joinConfigPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// ConfigError describes an error found in a configuration file
+type ConfigError struct {
+	Line    int    // Line number of the error, or 0 if it is not known
+	Path    string // Path of the setting in error, e.g. "listeners[0].network"
+	Message string // Description of the error
+}
+
+// Error returns the error message, prefixed with the line number and setting path
+func (e *ConfigError) Error() string {
+	s := e.Message
+	if e.Path != "" {
+		s = e.Path + ": " + s
+	}
+	if e.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", e.Line, s)
+	}
+	return s
+}
+
+// ConfigErrors is a list of errors found in a configuration file
+type ConfigErrors []*ConfigError
+
+// Error returns the error messages joined together
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, x := range e {
+		msgs[i] = x.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// covers returns whether there is already an error for the setting at the path, or for one of its children
+func (e ConfigErrors) covers(path string) bool {
+	for _, x := range e {
+		if x.Path == path || strings.HasPrefix(x.Path, path+".") || strings.HasPrefix(x.Path, path+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// sort orders the errors by line number
+func (e ConfigErrors) sort() {
+	sort.SliceStable(e, func(i, j int) bool { return e[i].Line < e[j].Line })
+}
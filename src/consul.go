@@ -0,0 +1,562 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/discovery"
+)
+
+// ConsulConfig defines how the services found are served through the Consul compatible web methods
+type ConsulConfig struct {
+	Enabled      bool     `json:"enabled"`                // Indicates whether the Consul compatible web methods are served.  Defaults to false
+	Datacenter   string   `json:"datacenter,omitempty"`   // Datacenter reported for the services.  Defaults to "dc1"
+	ServiceTypes []string `json:"serviceTypes,omitempty"` // Service types in the catalog, in addition to the service types of the registered services
+	Interval     int      `json:"interval,omitempty"`     // Interval between searches in secs.  Defaults to 10
+}
+
+// Validate checks the Consul configuration values for errors
+func (c *ConsulConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	for _, t := range c.ServiceTypes {
+		if !strings.HasPrefix(t, "_") {
+			return fmt.Errorf("invalid service type '%s'", t)
+		}
+	}
+	return nil
+}
+
+// SetDefaults checks the values and sets the defaults
+func (c *ConsulConfig) SetDefaults() {
+	if c.Datacenter == "" {
+		c.Datacenter = "dc1"
+	}
+	if c.Interval <= 0 {
+		c.Interval = 10
+	}
+}
+
+// consulName returns the Consul service name of a service type, e.g. "orders" for "_orders._tcp".
+// UDP service types are named with the protocol as a suffix, e.g. "orders-udp".  Service types that
+// would not map back to themselves with consulServiceType, such as "_orders._grpc._tcp", are used as
+// they are.
+func consulName(serviceType string) string {
+	t := strings.TrimSuffix(serviceType, ".")
+	n := strings.TrimPrefix(t, "_")
+	switch {
+	case strings.HasSuffix(n, "._tcp"):
+		n = strings.TrimSuffix(n, "._tcp")
+	case strings.HasSuffix(n, "._udp"):
+		n = strings.TrimSuffix(n, "._udp") + "-udp"
+	default:
+		return t
+	}
+	if n == "" || strings.Contains(n, ".") || consulServiceType(n) != t {
+		return t
+	}
+	return n
+}
+
+// consulServiceType returns the service type of a Consul service name.  Names that are already
+// service types, such as "_orders._tcp", are used as they are.
+func consulServiceType(name string) string {
+	if strings.HasPrefix(name, "_") {
+		return name
+	}
+	if strings.HasSuffix(name, "-udp") {
+		return "_" + strings.TrimSuffix(name, "-udp") + "._udp"
+	}
+	return "_" + name + "._tcp"
+}
+
+// ConsulCatalog keeps the instances of the service types served through the Consul compatible web
+// methods up to date, and counts the changes so that clients can wait for the catalog to change
+type ConsulCatalog struct {
+	Srv     *Server                 // Web Server
+	ctx     context.Context         // Stops watching for services when the server stops
+	watches map[string]*consulWatch // Services found, by service type
+	index   uint64                  // Number of changes to the catalog, used as the Consul index
+	changed chan struct{}           // Closed when the catalog changes
+	lock    sync.Mutex              // Mutex lock for the catalog
+}
+
+// consulWatch holds the instances found for a service type
+type consulWatch struct {
+	cancel   context.CancelFunc         // Stops watching for instances
+	ready    time.Time                  // Date and time the first search is complete
+	services map[string]api.ServiceItem // Instances found, by name
+}
+
+// NewConsulCatalog creates a catalog that watches for services using the server until the context is done
+func NewConsulCatalog(ctx context.Context, s *Server) *ConsulCatalog {
+	return &ConsulCatalog{
+		Srv:     s,
+		ctx:     ctx,
+		watches: map[string]*consulWatch{},
+		index:   1,
+		changed: make(chan struct{}),
+	}
+}
+
+// Sync watches the configured service types and the service types of the registered services,
+// and stops watching any others.  It returns the date and time the first search of the newest
+// service type is complete.
+func (g *ConsulCatalog) Sync(c ConsulConfig) time.Time {
+	types := map[string]bool{}
+	for _, t := range c.ServiceTypes {
+		types[t] = true
+	}
+	for _, i := range g.Srv.Registry.List().Services {
+		if strings.TrimSuffix(i.Domain, ".") == "local" {
+			types[i.ServiceType] = true
+		}
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	ready := time.Time{}
+	for t, w := range g.watches {
+		if !types[t] {
+			w.cancel()
+			delete(g.watches, t)
+			if len(w.services) != 0 {
+				g.notify()
+			}
+		}
+	}
+	for t := range types {
+		w, ok := g.watches[t]
+		if !ok {
+			// A service type that cannot be watched is tried again on the next sync
+			if w = g.watch(t, time.Duration(c.Interval)*time.Second); w == nil {
+				continue
+			}
+			g.watches[t] = w
+		}
+		if w.ready.After(ready) {
+			ready = w.ready
+		}
+	}
+	return ready
+}
+
+// watch starts watching for instances of the service type.  Returns nil if the service type
+// cannot be watched.
+func (g *ConsulCatalog) watch(serviceType string, interval time.Duration) *consulWatch {
+	ctx, cancel := context.WithCancel(g.ctx)
+	req := api.GetRequest{ServiceType: serviceType, Domain: "local"}
+	wt := g.Srv.WaitTime
+	if wt <= 0 {
+		wt = discovery.DefaultWaitTime
+	}
+	events, err := g.Srv.WatchServices(ctx, req, interval)
+	if err != nil {
+		cancel()
+		g.Srv.logError("Failed to watch services", LogServiceType, serviceType, LogError, err)
+		return nil
+	}
+	w := &consulWatch{
+		cancel: cancel,
+		// Replies are collected for the wait time before the first services are reported
+		ready:    time.Now().Add(time.Duration(wt)*time.Second + 500*time.Millisecond),
+		services: map[string]api.ServiceItem{},
+	}
+	go func() {
+		for e := range events {
+			g.lock.Lock()
+			if g.watches[serviceType] == w {
+				if e.Type == api.ServiceRemoved {
+					delete(w.services, e.Service.Name)
+				} else {
+					w.services[e.Service.Name] = e.Service
+				}
+				g.notify()
+			}
+			g.lock.Unlock()
+		}
+	}()
+	return w
+}
+
+// notify records a change to the catalog and wakes any waiting clients.  The lock must be held.
+func (g *ConsulCatalog) notify() {
+	g.index++
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// Wait waits until the catalog has changed since the index, the wait time has passed or the
+// context is done, and returns the current index
+func (g *ConsulCatalog) Wait(ctx context.Context, index uint64, wait time.Duration) uint64 {
+	g.lock.Lock()
+	if index == 0 || index != g.index {
+		defer g.lock.Unlock()
+		return g.index
+	}
+	ch := g.changed
+	g.lock.Unlock()
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ch:
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.index
+}
+
+// Services returns the instances of each service in the catalog that has instances, by Consul
+// name, and the current index
+func (g *ConsulCatalog) Services() (map[string][]api.ServiceItem, uint64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	m := map[string][]api.ServiceItem{}
+	for t, w := range g.watches {
+		if len(w.services) != 0 {
+			m[consulName(t)] = append(m[consulName(t)], sortedServices(w.services)...)
+		}
+	}
+	return m, g.index
+}
+
+// Instances returns the instances of the service with the Consul name, and the current index
+func (g *ConsulCatalog) Instances(name string) ([]api.ServiceItem, uint64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if w, ok := g.watches[consulServiceType(name)]; ok {
+		return sortedServices(w.services), g.index
+	}
+	return []api.ServiceItem{}, g.index
+}
+
+// sortedServices returns the services sorted by name
+func sortedServices(m map[string]api.ServiceItem) []api.ServiceItem {
+	l := make([]api.ServiceItem, 0, len(m))
+	for _, i := range m {
+		l = append(l, i)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+// consulNode is a node in the Consul catalog
+type consulNode struct {
+	ID              string            `json:"ID"`              // ID of the node
+	Node            string            `json:"Node"`            // Name of the node
+	Address         string            `json:"Address"`         // Address of the node
+	Datacenter      string            `json:"Datacenter"`      // Datacenter of the node
+	TaggedAddresses map[string]string `json:"TaggedAddresses"` // Addresses of the node by network
+	Meta            map[string]string `json:"Meta"`            // Node metadata
+}
+
+// consulService is a service instance in the Consul agent and health responses
+type consulService struct {
+	ID      string            `json:"ID"`      // ID of the service instance
+	Service string            `json:"Service"` // Name of the service
+	Tags    []string          `json:"Tags"`    // Tags of the service instance
+	Address string            `json:"Address"` // Address of the service instance
+	Port    int               `json:"Port"`    // Port of the service instance
+	Meta    map[string]string `json:"Meta"`    // Service metadata
+}
+
+// consulCatalogService is a service instance in the Consul catalog responses
+type consulCatalogService struct {
+	ID                       string            `json:"ID"`                       // ID of the node
+	Node                     string            `json:"Node"`                     // Name of the node
+	Address                  string            `json:"Address"`                  // Address of the node
+	Datacenter               string            `json:"Datacenter"`               // Datacenter of the node
+	TaggedAddresses          map[string]string `json:"TaggedAddresses"`          // Addresses of the node by network
+	NodeMeta                 map[string]string `json:"NodeMeta"`                 // Node metadata
+	ServiceID                string            `json:"ServiceID"`                // ID of the service instance
+	ServiceName              string            `json:"ServiceName"`              // Name of the service
+	ServiceTags              []string          `json:"ServiceTags"`              // Tags of the service instance
+	ServiceAddress           string            `json:"ServiceAddress"`           // Address of the service instance
+	ServicePort              int               `json:"ServicePort"`              // Port of the service instance
+	ServiceMeta              map[string]string `json:"ServiceMeta"`              // Service metadata
+	ServiceEnableTagOverride bool              `json:"ServiceEnableTagOverride"` // Always false
+	CreateIndex              uint64            `json:"CreateIndex"`              // Index the entry was created at
+	ModifyIndex              uint64            `json:"ModifyIndex"`              // Index the entry was last changed at
+}
+
+// consulCheck is a health check in the Consul health responses
+type consulCheck struct {
+	Node        string   `json:"Node"`        // Name of the node
+	CheckID     string   `json:"CheckID"`     // ID of the check
+	Name        string   `json:"Name"`        // Name of the check
+	Status      string   `json:"Status"`      // Status of the check, either "passing" or "critical"
+	Notes       string   `json:"Notes"`       // Notes about the check
+	Output      string   `json:"Output"`      // Output of the check
+	ServiceID   string   `json:"ServiceID"`   // ID of the service instance checked
+	ServiceName string   `json:"ServiceName"` // Name of the service checked
+	ServiceTags []string `json:"ServiceTags"` // Tags of the service instance checked
+}
+
+// consulServiceEntry is a service instance in the Consul health responses
+type consulServiceEntry struct {
+	Node    consulNode    `json:"Node"`    // Node the service instance runs on
+	Service consulService `json:"Service"` // Service instance
+	Checks  []consulCheck `json:"Checks"`  // Health checks of the service instance
+}
+
+// consulRegistration is a service registration sent to the Consul agent web method
+type consulRegistration struct {
+	ID      string             `json:"ID"`      // ID of the service.  Defaults to the name
+	Name    string             `json:"Name"`    // Name of the service, used as the service type
+	Tags    []string           `json:"Tags"`    // Tags, announced as TXT entries
+	Port    int                `json:"Port"`    // Port of the service
+	Address string             `json:"Address"` // IP address of the service, if it runs on another machine
+	Meta    map[string]string  `json:"Meta"`    // Metadata, announced as key=value TXT entries
+	Check   *consulAgentCheck  `json:"Check"`   // Health check of the service
+	Checks  []consulAgentCheck `json:"Checks"`  // Health checks of the service.  Only one is supported
+}
+
+// consulAgentCheck is a health check sent with a service registration
+type consulAgentCheck struct {
+	HTTP       string   `json:"HTTP"`       // URL requested by an HTTP check
+	TCP        string   `json:"TCP"`        // Address connected to by a TCP check
+	Args       []string `json:"Args"`       // Command run by a script check
+	ScriptArgs []string `json:"ScriptArgs"` // Command run by a script check, in older clients
+	TTL        string   `json:"TTL"`        // TTL checks are not supported
+	Interval   string   `json:"Interval"`   // Interval between checks, e.g. "10s"
+	Timeout    string   `json:"Timeout"`    // Maximum duration of a check, e.g. "2s"
+}
+
+// consulEntries returns the tags and metadata of a service instance.  Every TXT entry is a tag,
+// and every key=value TXT entry is also a metadata value.
+func consulEntries(i api.ServiceItem) ([]string, map[string]string) {
+	tags := []string{}
+	meta := map[string]string{}
+	for _, t := range i.Text {
+		if t == "" {
+			continue
+		}
+		tags = append(tags, t)
+		if k := api.TextKey(t); k != t {
+			meta[k] = t[len(k)+1:]
+		}
+	}
+	return tags, meta
+}
+
+// consulAddress returns the address of a service instance, preferring IPv4
+func consulAddress(i api.ServiceItem) string {
+	if len(i.AddrIPv4) != 0 {
+		return i.AddrIPv4[0].String()
+	}
+	if len(i.AddrIPv6) != 0 {
+		return i.AddrIPv6[0].String()
+	}
+	return strings.TrimSuffix(i.HostName, ".")
+}
+
+// hasTags returns whether the tags include all of the required tags
+func hasTags(tags []string, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, t := range tags {
+			if t == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// NewCatalogService returns the Consul catalog entry of a service instance
+func NewCatalogService(i api.ServiceItem, dc string, index uint64) consulCatalogService {
+	tags, meta := consulEntries(i)
+	addr := consulAddress(i)
+	return consulCatalogService{
+		Node:            strings.TrimSuffix(i.HostName, "."),
+		Address:         addr,
+		Datacenter:      dc,
+		TaggedAddresses: map[string]string{"lan": addr},
+		NodeMeta:        map[string]string{},
+		ServiceID:       i.Name,
+		ServiceName:     consulName(i.Service),
+		ServiceTags:     tags,
+		ServiceAddress:  addr,
+		ServicePort:     i.Port,
+		ServiceMeta:     meta,
+		CreateIndex:     index,
+		ModifyIndex:     index,
+	}
+}
+
+// NewServiceEntry returns the Consul health entry of a service instance.  The instance has a single
+// check, which is passing unless the instance publishes an unhealthy state.
+func NewServiceEntry(i api.ServiceItem, dc string) consulServiceEntry {
+	tags, meta := consulEntries(i)
+	addr := consulAddress(i)
+	node := strings.TrimSuffix(i.HostName, ".")
+	name := consulName(i.Service)
+	status := "passing"
+	if !i.IsHealthy() {
+		status = "critical"
+	}
+	return consulServiceEntry{
+		Node: consulNode{
+			Node:            node,
+			Address:         addr,
+			Datacenter:      dc,
+			TaggedAddresses: map[string]string{"lan": addr},
+			Meta:            map[string]string{},
+		},
+		Service: consulService{ID: i.Name, Service: name, Tags: tags, Address: addr, Port: i.Port, Meta: meta},
+		Checks: []consulCheck{{
+			Node:        node,
+			CheckID:     "service:" + i.Name,
+			Name:        "Service '" + name + "' check",
+			Status:      status,
+			ServiceID:   i.Name,
+			ServiceName: name,
+			ServiceTags: tags,
+		}},
+	}
+}
+
+// RegisterRequest returns the zcservice registration request of the Consul service registration
+func (c *consulRegistration) RegisterRequest() (*api.RegisterRequest, error) {
+	if c.Name == "" {
+		return nil, errors.New("service name is missing")
+	}
+	req := &api.RegisterRequest{
+		ID:          c.ID,
+		Name:        c.Name,
+		PortNo:      c.Port,
+		ServiceType: consulServiceType(c.Name),
+		Text:        append([]string{}, c.Tags...),
+	}
+	if req.ID == "" {
+		req.ID = c.Name
+	}
+	keys := make([]string, 0, len(c.Meta))
+	for k := range c.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		req.Text = append(req.Text, k+"="+c.Meta[k])
+	}
+
+	if c.Address != "" {
+		ip := net.ParseIP(c.Address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address '%s', must be an IP address", c.Address)
+		}
+		if !ip.IsLoopback() && !isLocalIP(ip) {
+			req.Host = "ip-" + strings.NewReplacer(".", "-", ":", "-").Replace(ip.String())
+			req.IPs = []string{ip.String()}
+		}
+	}
+
+	checks := c.Checks
+	if c.Check != nil {
+		checks = append([]consulAgentCheck{*c.Check}, checks...)
+	}
+	if len(checks) > 1 {
+		return nil, errors.New("only one check is supported")
+	}
+	if len(checks) == 1 {
+		hc, err := checks[0].HealthCheck(c.Port)
+		if err != nil {
+			return nil, err
+		}
+		req.HealthCheck = hc
+	}
+	return req, nil
+}
+
+// HealthCheck returns the zcservice health check of the Consul check.  HTTP and TCP checks must
+// use the port of the service.
+func (c *consulAgentCheck) HealthCheck(port int) (*api.HealthCheck, error) {
+	hc := &api.HealthCheck{}
+	var err error
+	if hc.Interval, err = consulSeconds(c.Interval); err != nil {
+		return nil, fmt.Errorf("invalid check interval '%s'", c.Interval)
+	}
+	if hc.Timeout, err = consulSeconds(c.Timeout); err != nil {
+		return nil, fmt.Errorf("invalid check timeout '%s'", c.Timeout)
+	}
+	args := c.Args
+	if len(args) == 0 {
+		args = c.ScriptArgs
+	}
+	host, p := "", ""
+	switch {
+	case c.HTTP != "":
+		u, err := url.Parse(c.HTTP)
+		if err != nil || u.Scheme != "http" {
+			return nil, fmt.Errorf("invalid check URL '%s'", c.HTTP)
+		}
+		hc.Type = "http"
+		hc.Path = u.RequestURI()
+		host, p = u.Hostname(), u.Port()
+		if p == "" {
+			p = "80"
+		}
+	case c.TCP != "":
+		hc.Type = "tcp"
+		if host, p, err = net.SplitHostPort(c.TCP); err != nil {
+			return nil, fmt.Errorf("invalid check address '%s'", c.TCP)
+		}
+	case len(args) != 0:
+		hc.Type = "script"
+		hc.Script = args[0]
+		hc.Args = args[1:]
+		return hc, nil
+	case c.TTL != "":
+		return nil, errors.New("TTL checks are not supported")
+	default:
+		return nil, errors.New("check must be an HTTP, TCP or script check")
+	}
+	if p != strconv.Itoa(port) {
+		return nil, errors.New("checks must use the port of the service")
+	}
+	hc.Host = host
+	return hc, nil
+}
+
+// consulSeconds returns a Consul duration, such as "10s", in whole seconds rounded up
+func consulSeconds(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid duration")
+	}
+	return int((d + time.Second - 1) / time.Second), nil
+}
+
+// isLocalIP returns whether the IP address belongs to this machine
+func isLocalIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+func TestConsulName(t *testing.T) {
+	tests := []struct {
+		serviceType string
+		name        string
+	}{
+		{"_orders._tcp", "orders"},
+		{"_orders._tcp.", "orders"},
+		{"_dns._udp", "dns-udp"},
+		{"_my-svc._tcp", "my-svc"},
+		{"_orders._grpc._tcp", "_orders._grpc._tcp"},
+		{"_backup-udp._tcp", "_backup-udp._tcp"},
+		{"_orders", "_orders"},
+		{"orders._tcp", "orders._tcp"},
+		{"_._tcp", "_._tcp"},
+	}
+	for _, tt := range tests {
+		if got := consulName(tt.serviceType); got != tt.name {
+			t.Errorf("consulName(%q) = %q, want %q", tt.serviceType, got, tt.name)
+		}
+	}
+}
+
+func TestConsulServiceType(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceType string
+	}{
+		{"orders", "_orders._tcp"},
+		{"dns-udp", "_dns._udp"},
+		{"my-svc", "_my-svc._tcp"},
+		{"_orders._grpc._tcp", "_orders._grpc._tcp"},
+		{"_web._tcp", "_web._tcp"},
+	}
+	for _, tt := range tests {
+		if got := consulServiceType(tt.name); got != tt.serviceType {
+			t.Errorf("consulServiceType(%q) = %q, want %q", tt.name, got, tt.serviceType)
+		}
+	}
+}
+
+func TestConsulNameRoundTrip(t *testing.T) {
+	for _, st := range []string{
+		"_orders._tcp",
+		"_dns._udp",
+		"_my-svc._tcp",
+		"_orders._grpc._tcp",
+		"_backup-udp._tcp",
+		"_printer._sub._http._tcp",
+		"_a._b._udp",
+	} {
+		if got := consulServiceType(consulName(st)); got != st {
+			t.Errorf("consulServiceType(consulName(%q)) = %q, want %q", st, got, st)
+		}
+	}
+}
+
+func TestConsulRegistrationRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		reg  consulRegistration
+		want *api.RegisterRequest
+		err  bool
+	}{
+		{
+			name: "defaults the id to the name",
+			reg:  consulRegistration{Name: "orders", Port: 8080},
+			want: &api.RegisterRequest{ID: "orders", Name: "orders", PortNo: 8080, ServiceType: "_orders._tcp", Text: []string{}},
+		},
+		{
+			name: "tags then metadata sorted by key",
+			reg:  consulRegistration{ID: "o1", Name: "dns-udp", Port: 53, Tags: []string{"primary"}, Meta: map[string]string{"zone": "a", "version": "2", "env": "prod"}},
+			want: &api.RegisterRequest{ID: "o1", Name: "dns-udp", PortNo: 53, ServiceType: "_dns._udp", Text: []string{"primary", "env=prod", "version=2", "zone=a"}},
+		},
+		{
+			name: "remote address is announced for another host",
+			reg:  consulRegistration{Name: "orders", Port: 8080, Address: "192.0.2.10"},
+			want: &api.RegisterRequest{ID: "orders", Name: "orders", PortNo: 8080, ServiceType: "_orders._tcp", Text: []string{}, Host: "ip-192-0-2-10", IPs: []string{"192.0.2.10"}},
+		},
+		{
+			name: "remote ipv6 address",
+			reg:  consulRegistration{Name: "orders", Port: 8080, Address: "2001:db8::1"},
+			want: &api.RegisterRequest{ID: "orders", Name: "orders", PortNo: 8080, ServiceType: "_orders._tcp", Text: []string{}, Host: "ip-2001-db8--1", IPs: []string{"2001:db8::1"}},
+		},
+		{
+			name: "loopback address is this host",
+			reg:  consulRegistration{Name: "orders", Port: 8080, Address: "127.0.0.1"},
+			want: &api.RegisterRequest{ID: "orders", Name: "orders", PortNo: 8080, ServiceType: "_orders._tcp", Text: []string{}},
+		},
+		{
+			name: "check",
+			reg:  consulRegistration{Name: "orders", Port: 8080, Check: &consulAgentCheck{HTTP: "http://127.0.0.1:8080/health", Interval: "10s"}},
+			want: &api.RegisterRequest{ID: "orders", Name: "orders", PortNo: 8080, ServiceType: "_orders._tcp", Text: []string{}, HealthCheck: &api.HealthCheck{Type: "http", Host: "127.0.0.1", Path: "/health", Interval: 10}},
+		},
+		{name: "missing name", reg: consulRegistration{Port: 8080}, err: true},
+		{name: "host name address", reg: consulRegistration{Name: "orders", Port: 8080, Address: "orders.example.com"}, err: true},
+		{name: "more than one check", reg: consulRegistration{Name: "orders", Port: 8080, Check: &consulAgentCheck{TCP: "127.0.0.1:8080"}, Checks: []consulAgentCheck{{TCP: "127.0.0.1:8080"}}}, err: true},
+		{name: "invalid check", reg: consulRegistration{Name: "orders", Port: 8080, Checks: []consulAgentCheck{{TTL: "30s"}}}, err: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.reg.RegisterRequest()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: RegisterRequest() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConsulAgentCheckHealthCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check consulAgentCheck
+		want  *api.HealthCheck
+		err   bool
+	}{
+		{
+			name:  "http",
+			check: consulAgentCheck{HTTP: "http://10.0.0.5:8080/health?full=1", Interval: "15s", Timeout: "1500ms"},
+			want:  &api.HealthCheck{Type: "http", Host: "10.0.0.5", Path: "/health?full=1", Interval: 15, Timeout: 2},
+		},
+		{
+			name:  "tcp",
+			check: consulAgentCheck{TCP: "localhost:8080"},
+			want:  &api.HealthCheck{Type: "tcp", Host: "localhost"},
+		},
+		{
+			name:  "script",
+			check: consulAgentCheck{Args: []string{"/bin/check", "-q"}},
+			want:  &api.HealthCheck{Type: "script", Script: "/bin/check", Args: []string{"-q"}},
+		},
+		{
+			name:  "script in an older client",
+			check: consulAgentCheck{ScriptArgs: []string{"/bin/check"}},
+			want:  &api.HealthCheck{Type: "script", Script: "/bin/check", Args: []string{}},
+		},
+		{name: "http port mismatch", check: consulAgentCheck{HTTP: "http://127.0.0.1:9090/health"}, err: true},
+		{name: "http default port mismatch", check: consulAgentCheck{HTTP: "http://127.0.0.1/health"}, err: true},
+		{name: "tcp port mismatch", check: consulAgentCheck{TCP: "127.0.0.1:9090"}, err: true},
+		{name: "https", check: consulAgentCheck{HTTP: "https://127.0.0.1:8080/health"}, err: true},
+		{name: "tcp without port", check: consulAgentCheck{TCP: "127.0.0.1"}, err: true},
+		{name: "ttl", check: consulAgentCheck{TTL: "30s"}, err: true},
+		{name: "no check", check: consulAgentCheck{}, err: true},
+		{name: "invalid interval", check: consulAgentCheck{TCP: "127.0.0.1:8080", Interval: "often"}, err: true},
+		{name: "negative timeout", check: consulAgentCheck{TCP: "127.0.0.1:8080", Timeout: "-1s"}, err: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.check.HealthCheck(8080)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: HealthCheck() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConsulCatalogWait(t *testing.T) {
+	g := NewConsulCatalog(context.Background(), nil)
+
+	// A new or out of date index returns at once
+	start := time.Now()
+	if got := g.Wait(context.Background(), 0, time.Minute); got != 1 {
+		t.Errorf("Wait(0) = %d, want 1", got)
+	}
+	if got := g.Wait(context.Background(), 7, time.Minute); got != 1 {
+		t.Errorf("Wait(7) = %d, want 1", got)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Wait blocked for an index that is not current")
+	}
+
+	// The current index blocks until the wait time has passed
+	start = time.Now()
+	if got := g.Wait(context.Background(), 1, 100*time.Millisecond); got != 1 {
+		t.Errorf("Wait(1) after the wait time = %d, want 1", got)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("Wait returned before the wait time had passed")
+	}
+
+	// A change wakes the waiting client with the new index
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		g.lock.Lock()
+		g.notify()
+		g.lock.Unlock()
+	}()
+	start = time.Now()
+	if got := g.Wait(context.Background(), 1, time.Minute); got != 2 {
+		t.Errorf("Wait(1) after a change = %d, want 2", got)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("Wait was not woken by the change")
+	}
+
+	// The end of the request wakes the waiting client
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if got := g.Wait(ctx, 2, time.Minute); got != 2 {
+		t.Errorf("Wait(2) after the request ended = %d, want 2", got)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("Wait was not woken by the end of the request")
+	}
+}
+
+func TestConsulCatalogSyncRetriesFailedWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &Server{Registry: registry.New()}
+	defer s.Registry.Close()
+	s.config.Store(&Config{})
+	g := NewConsulCatalog(ctx, s)
+
+	// A service type that cannot be watched is not kept, so that it is tried again
+	g.Sync(ConsulConfig{ServiceTypes: []string{""}, Interval: 10})
+	g.Sync(ConsulConfig{ServiceTypes: []string{""}, Interval: 10})
+	if len(g.watches) != 0 {
+		t.Errorf("Sync kept %d failed watches, want 0", len(g.watches))
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+const (
+	consulDefaultWait = 5 * time.Minute  // Time a blocking query waits for a change if the client does not say
+	consulMaxWait     = 10 * time.Minute // Longest time a blocking query waits for a change
+)
+
+// ConsulController handles a subset of the Consul catalog, health and agent web methods, so that
+// tools that can discover services using Consul can discover the services found with zeroconf
+type ConsulController struct {
+	Srv     *Server        // Web Server
+	catalog *ConsulCatalog // Services served through the web methods
+}
+
+// AddController adds the controller routes to the router
+func (c *ConsulController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-s.exit
+		cancel()
+	}()
+	c.catalog = NewConsulCatalog(ctx, s)
+	router.Methods("GET").Path("/v1/catalog/services").
+		Handler(Logger(c, http.HandlerFunc(c.handleServices)))
+	router.Methods("GET").Path("/v1/catalog/service/{name}").
+		Handler(Logger(c, http.HandlerFunc(c.handleCatalogService)))
+	router.Methods("GET").Path("/v1/health/service/{name}").
+		Handler(Logger(c, http.HandlerFunc(c.handleHealthService)))
+	router.Methods("PUT").Path("/v1/agent/service/register").
+		Handler(Logger(c, http.HandlerFunc(c.handleRegister)))
+	router.Methods("PUT").Path("/v1/agent/service/deregister/{id}").
+		Handler(Logger(c, http.HandlerFunc(c.handleDeregister)))
+	router.Methods("GET").Path("/v1/agent/self").
+		Handler(Logger(c, http.HandlerFunc(c.handleSelf)))
+}
+
+// handleServices handles the /v1/catalog/services web method call
+func (c *ConsulController) handleServices(w http.ResponseWriter, r *http.Request) {
+	if _, ok := c.query(w, r); !ok {
+		return
+	}
+	services, index := c.catalog.Services()
+	resp := map[string][]string{}
+	for n, l := range services {
+		seen := map[string]bool{}
+		tags := []string{}
+		for _, i := range l {
+			t, _ := consulEntries(i)
+			for _, v := range t {
+				if !seen[v] {
+					seen[v] = true
+					tags = append(tags, v)
+				}
+			}
+		}
+		sort.Strings(tags)
+		resp[n] = tags
+	}
+	c.writeJSON(w, index, resp)
+}
+
+// handleCatalogService handles the /v1/catalog/service/{name} web method call
+func (c *ConsulController) handleCatalogService(w http.ResponseWriter, r *http.Request) {
+	cfg, ok := c.query(w, r)
+	if !ok {
+		return
+	}
+	services, index := c.catalog.Instances(mux.Vars(r)["name"])
+	tags := r.URL.Query()["tag"]
+	resp := []consulCatalogService{}
+	for _, i := range services {
+		e := NewCatalogService(i, cfg.Datacenter, index)
+		if hasTags(e.ServiceTags, tags) {
+			resp = append(resp, e)
+		}
+	}
+	c.writeJSON(w, index, resp)
+}
+
+// handleHealthService handles the /v1/health/service/{name} web method call
+func (c *ConsulController) handleHealthService(w http.ResponseWriter, r *http.Request) {
+	cfg, ok := c.query(w, r)
+	if !ok {
+		return
+	}
+	services, index := c.catalog.Instances(mux.Vars(r)["name"])
+	q := r.URL.Query()
+	tags := q["tag"]
+	_, passing := q["passing"]
+	if v := q.Get("passing"); v != "" {
+		passing, _ = strconv.ParseBool(v)
+	}
+	resp := []consulServiceEntry{}
+	for _, i := range services {
+		if passing && !i.IsHealthy() {
+			continue
+		}
+		e := NewServiceEntry(i, cfg.Datacenter)
+		if hasTags(e.Service.Tags, tags) {
+			resp = append(resp, e)
+		}
+	}
+	c.writeJSON(w, index, resp)
+}
+
+// handleRegister handles the /v1/agent/service/register web method call
+func (c *ConsulController) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if !c.Srv.Config().Consul.Enabled {
+		http.Error(w, "Consul web methods are not enabled.", 404)
+		return
+	}
+	reg := consulRegistration{}
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, "Invalid Registration. "+err.Error(), 400)
+		return
+	}
+	req, err := reg.RegisterRequest()
+	if err != nil {
+		http.Error(w, "Invalid Registration. "+err.Error(), 400)
+		return
+	}
+	if _, rerr := c.Srv.RegisterService(req, CallerFromRequest(r)); rerr != nil {
+		http.Error(w, rerr.Message, rerr.Status)
+	}
+}
+
+// handleDeregister handles the /v1/agent/service/deregister/{id} web method call
+func (c *ConsulController) handleDeregister(w http.ResponseWriter, r *http.Request) {
+	if !c.Srv.Config().Consul.Enabled {
+		http.Error(w, "Consul web methods are not enabled.", 404)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if err := c.Srv.CheckDeregister(id); err != nil {
+		http.Error(w, err.Message, err.Status)
+		return
+	}
+	if c.Srv.Registry.Get(id) == nil {
+		http.Error(w, "Unknown service ID "+strconv.Quote(id)+". Ensure that the service ID is passed, not the service name.", 404)
+		return
+	}
+	c.Srv.Registry.Deregister(id, registry.ReasonRequested, CallerFromRequest(r))
+}
+
+// handleSelf handles the /v1/agent/self web method call, which clients use to find out the datacenter
+func (c *ConsulController) handleSelf(w http.ResponseWriter, r *http.Request) {
+	sc := c.Srv.Config()
+	cfg := sc.Consul
+	if !cfg.Enabled {
+		http.Error(w, "Consul web methods are not enabled.", 404)
+		return
+	}
+	cfg.SetDefaults()
+	c.writeJSON(w, 0, map[string]interface{}{
+		"Config": map[string]interface{}{
+			"Datacenter": cfg.Datacenter,
+			"NodeName":   c.Srv.Registry.HostName,
+			"NodeID":     sc.ID,
+		},
+		"Member": map[string]interface{}{
+			"Name": c.Srv.Registry.HostName,
+		},
+	})
+}
+
+// query checks that the Consul web methods are enabled, brings the catalog up to date and waits
+// for it to change if the request is a blocking query.  It returns the Consul configuration, and
+// false if an error response was written.
+func (c *ConsulController) query(w http.ResponseWriter, r *http.Request) (ConsulConfig, bool) {
+	cfg := c.Srv.Config().Consul
+	if !cfg.Enabled {
+		http.Error(w, "Consul web methods are not enabled.", 404)
+		return cfg, false
+	}
+	cfg.SetDefaults()
+	q := r.URL.Query()
+	index := uint64(0)
+	if v := q.Get("index"); v != "" {
+		i, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid index.", 400)
+			return cfg, false
+		}
+		index = i
+	}
+	wait := consulDefaultWait
+	if v := q.Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, "Invalid wait time.", 400)
+			return cfg, false
+		}
+		wait = d
+	}
+	if wait > consulMaxWait {
+		wait = consulMaxWait
+	}
+
+	// Give new service types time for the first search to complete
+	if d := time.Until(c.catalog.Sync(cfg)); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return cfg, false
+		}
+	}
+	c.catalog.Wait(r.Context(), index, wait)
+	return cfg, true
+}
+
+// writeJSON writes the value as the json response, with the Consul index headers
+func (c *ConsulController) writeJSON(w http.ResponseWriter, index uint64, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	if index != 0 {
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+		w.Header().Set("X-Consul-KnownLeader", "true")
+		w.Header().Set("X-Consul-LastContact", "0")
+	}
+	w.Write(b)
+}
+
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *ConsulController) LogInfo(msg string, args ...interface{}) {
+	componentLog("ConsulController").Info(msg, args...)
+}
@@ -0,0 +1,122 @@
+// Package discovery searches the network for services announced with zeroconf
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// DefaultWaitTime is the time in secs to wait for replies if the request does not specify one
+const DefaultWaitTime = 3
+
+// Error is returned when a zeroconf operation fails
+type Error struct {
+	Op  string // Operation that failed, either "resolver", "browse" or "lookup"
+	Err error  // Error returned by zeroconf
+}
+
+// Error returns the error message, prefixed with the operation
+func (e *Error) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by zeroconf
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewServiceItem returns a ServiceItem object loaded with the values from the zeroconf service entry record
+func NewServiceItem(e *zeroconf.ServiceEntry) api.ServiceItem {
+	return api.ServiceItem{
+		Name:     e.Instance,
+		HostName: e.HostName,
+		Port:     e.Port,
+		Service:  e.Service,
+		Domain:   e.Domain,
+		Text:     e.Text,
+		AddrIPv4: e.AddrIPv4,
+		AddrIPv6: e.AddrIPv6,
+	}
+}
+
+// Browse searches for services based on the search criteria passed in the request.
+// It waits for replies for the WaitTime of the request, or until the context is done.
+func Browse(ctx context.Context, r api.GetRequest) (api.GetResponse, error) {
+	r.SetDefaults()
+	wt := r.WaitTime
+	if wt <= 0 {
+		wt = DefaultWaitTime
+	}
+	resp := r.CreateResponse()
+	if r.ServiceType == "" {
+		return resp, errors.New("service type is missing")
+	}
+
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return resp, &Error{Op: "resolver", Err: err}
+	}
+	var lock sync.Mutex
+	entries := make(chan *zeroconf.ServiceEntry)
+	go func(results <-chan *zeroconf.ServiceEntry) {
+		for entry := range results {
+			i := NewServiceItem(entry)
+			if r.OnlyHealthy && !i.IsHealthy() {
+				continue
+			}
+			lock.Lock()
+			resp.Services = append(resp.Services, i)
+			lock.Unlock()
+		}
+	}(entries)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(wt))
+	defer cancel()
+	if err := resolver.Browse(ctx, r.ServiceType, r.Domain, entries); err != nil {
+		return resp, &Error{Op: "browse", Err: err}
+	}
+
+	<-ctx.Done()
+
+	lock.Lock()
+	defer lock.Unlock()
+	return resp, nil
+}
+
+// Lookup searches for the service instance with the specified name, type and domain.
+// Returns nil if the instance is not found before the context is done.
+func Lookup(ctx context.Context, name string, serviceType string, domain string) (*api.ServiceItem, error) {
+	if domain == "" {
+		domain = "local"
+	}
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, &Error{Op: "resolver", Err: err}
+	}
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := make(chan api.ServiceItem, 1)
+	go func(results <-chan *zeroconf.ServiceEntry) {
+		for e := range results {
+			if e.Instance == name {
+				found <- NewServiceItem(e)
+				return
+			}
+		}
+	}(entries)
+
+	if err := resolver.Lookup(ctx, name, serviceType, domain, entries); err != nil {
+		return nil, &Error{Op: "lookup", Err: err}
+	}
+	select {
+	case i := <-found:
+		return &i, nil
+	case <-ctx.Done():
+		return nil, nil
+	}
+}
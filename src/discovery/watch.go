@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// DefaultWatchInterval is the interval between searches if Watch is not given one
+const DefaultWatchInterval = 10 * time.Second
+
+// watchMisses is the number of searches in a row a service must be missing from before it is removed.
+// A single search can miss an instance if its reply is lost.
+const watchMisses = 2
+
+// Watch searches for services based on the search criteria passed in the request at the
+// specified interval, and returns a channel that receives an event each time a service
+// instance is found, changes or is no longer found.  The channel is closed when the
+// context is done.
+//
+// zeroconf does not report services that go away, so services are searched for repeatedly,
+// each search waiting for replies for the WaitTime of the request.
+func Watch(ctx context.Context, r api.GetRequest, interval time.Duration) (<-chan api.ServiceEvent, error) {
+	if r.ServiceType == "" {
+		return nil, errors.New("service type is missing")
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	events := make(chan api.ServiceEvent)
+	go func() {
+		defer close(events)
+		found := map[string]api.ServiceItem{}
+		misses := map[string]int{}
+		send := func(t string, i api.ServiceItem) bool {
+			select {
+			case events <- api.ServiceEvent{Time: time.Now(), Type: t, Service: i}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			resp, err := Browse(ctx, r)
+			if ctx.Err() != nil {
+				return
+			}
+			// Leave the services as they are if the search failed
+			if err == nil {
+				seen := map[string]bool{}
+				for _, i := range resp.Services {
+					k := serviceKey(i)
+					seen[k] = true
+					misses[k] = 0
+					if o, ok := found[k]; !ok {
+						found[k] = i
+						if !send(api.ServiceAdded, i) {
+							return
+						}
+					} else if isServiceChanged(o, i) {
+						found[k] = i
+						if !send(api.ServiceUpdated, i) {
+							return
+						}
+					}
+				}
+				for k, i := range found {
+					if seen[k] {
+						continue
+					}
+					misses[k]++
+					if misses[k] >= watchMisses {
+						delete(found, k)
+						delete(misses, k)
+						if !send(api.ServiceRemoved, i) {
+							return
+						}
+					}
+				}
+			}
+
+			t := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return events, nil
+}
+
+// serviceKey returns the key that identifies a service instance
+func serviceKey(i api.ServiceItem) string {
+	return i.Name + "." + i.Service + "." + i.Domain
+}
+
+// isServiceChanged returns whether the port, host, addresses or Text of the service instance changed
+func isServiceChanged(a api.ServiceItem, b api.ServiceItem) bool {
+	if a.Port != b.Port || a.HostName != b.HostName {
+		return true
+	}
+	if strings.Join(a.Text, "\x00") != strings.Join(b.Text, "\x00") {
+		return true
+	}
+	return ipsString(a.AddrIPv4) != ipsString(b.AddrIPv4) || ipsString(a.AddrIPv6) != ipsString(b.AddrIPv6)
+}
+
+// ipsString returns the IP addresses as a single string for comparison
+func ipsString(ips []net.IP) string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	// The addresses are not always returned in the same order
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
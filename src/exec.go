@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+// readyInterval is the time between readiness probes
+const readyInterval = 500 * time.Millisecond
+
+// execValues holds the flags of the exec command
+type execValues struct {
+	Ready        string // Address to connect to before the service is announced, if any
+	ReadyTimeout int    // Duration in secs to wait for the service to be ready.  0 waits for as long as the program runs
+}
+
+// execFlags adds the flags of the exec command
+func execFlags(fs *flag.FlagSet, o *cmdOptions) {
+	registerFlags(fs, o)
+	fs.StringVar(&o.Exec.Ready, "ready", "", "Address to connect to over TCP before the service is announced, e.g. \"127.0.0.1:8080\".")
+	fs.IntVar(&o.Exec.ReadyTimeout, "ready-timeout", 0, "Duration in secs to wait for the service to be ready before the program is stopped.  0 waits for as long as the program runs.")
+}
+
+// runExec runs a program and registers it as a service while it runs.  Signals are forwarded to
+// the program, and the service is deregistered when it exits.  The exit code is that of the program.
+func runExec(o *cmdOptions, args []string) int {
+	if len(args) == 0 {
+		o.usage()
+		return exitUsage
+	}
+	req, code := o.registerRequest()
+	if code != exitOK {
+		return code
+	}
+	if req.PID != 0 {
+		fmt.Fprintf(o.Err, "zcservice %s: the service is registered for the program, so -pid cannot be used\n", o.Name)
+		return exitUsage
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+	if err := cmd.Start(); err != nil {
+		return o.fail(err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan error, 1)
+	go func() {
+		ready <- o.waitReady(ctx)
+	}()
+
+	var deregister func()
+	failCode := exitOK
+	for {
+		select {
+		case s := <-sigs:
+			cmd.Process.Signal(s)
+		case err := <-ready:
+			if err == nil {
+				deregister, err = o.advertise(ctx, req, cmd.Process.Pid)
+			}
+			if err != nil {
+				// The program is stopped, rather than left running without being announced
+				failCode = o.fail(err)
+				stopProcess(cmd.Process)
+			}
+		case err := <-exited:
+			cancel()
+			if deregister != nil {
+				deregister()
+			}
+			if failCode != exitOK {
+				return failCode
+			}
+			return exitStatus(err)
+		}
+	}
+}
+
+// waitReady waits until a TCP connection can be made to the readiness address, if there is one
+func (o *cmdOptions) waitReady(ctx context.Context) error {
+	if o.Exec.Ready == "" {
+		return nil
+	}
+	if o.Exec.ReadyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(o.Exec.ReadyTimeout)*time.Second)
+		defer cancel()
+	}
+	d := net.Dialer{Timeout: time.Second}
+	for {
+		if conn, err := d.DialContext(ctx, "tcp", o.Exec.Ready); err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s was not ready after %d secs", o.Exec.Ready, o.Exec.ReadyTimeout)
+		case <-time.After(readyInterval):
+		}
+	}
+}
+
+// advertise registers the service for the program with the process ID, and returns the function
+// that deregisters it.  The daemon registration is renewed until the context is done.
+func (o *cmdOptions) advertise(ctx context.Context, req api.RegisterRequest, pid int) (func(), error) {
+	if o.Standalone {
+		reg := registry.New()
+		reg.Log = func(c string) *slog.Logger {
+			return slog.New(slog.NewTextHandler(o.Err, &slog.HandlerOptions{Level: slog.LevelWarn})).With(LogComponent, c)
+		}
+		req.PID = pid
+		reg.Register(&req, api.SourceAPI, registry.Caller{})
+		return reg.Close, nil
+	}
+	// Over a Unix socket, the daemon also deregisters the service if this process is killed.
+	// A daemon on this host watches the program, so the service does not outlive it if this
+	// process is killed over TCP.
+	req.WatchOwner = true
+	if isLocalAddress(o.Addr) {
+		req.PID = pid
+	}
+	r, err := o.client().Register(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		<-r.Done()
+	}, nil
+}
+
+// isLocalAddress returns whether the daemon address is on this host
+func isLocalAddress(addr string) bool {
+	if addr == "" || strings.HasPrefix(addr, "unix://") {
+		return true
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// stopProcess asks the process to stop, or kills it if it cannot be signalled
+func stopProcess(p *os.Process) {
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		p.Kill()
+	}
+}
+
+// exitStatus returns the exit code for the result of running the program.
+// A program killed by a signal returns 128 plus the signal number, as a shell does.
+func exitStatus(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) {
+		return exitError
+	}
+	if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ee.ExitCode()
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestIsLocalAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want bool
+	}{
+		{"default", "", true},
+		{"unix socket", "unix:///run/zcservice.sock", true},
+		{"loopback", "http://127.0.0.1:20404", true},
+		{"ipv6 loopback", "http://[::1]:20404", true},
+		{"localhost", "http://localhost:20404", true},
+		{"remote ip", "http://192.168.1.10:20404", false},
+		{"remote host", "https://zc.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := isLocalAddress(tt.addr); got != tt.want {
+			t.Errorf("%s: isLocalAddress(%q) = %v, want %v", tt.name, tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardSignals are the signals the exec command forwards to the program it runs
+var forwardSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileWatcher watches a directory for changes to matching files and calls OnChange
+// once the changes have settled
+type FileWatcher struct {
+	Dir      string                 // Directory to watch
+	Match    func(name string) bool // Returns whether a change to the named file is of interest.  If nil, all files match
+	OnChange func()                 // Called after matching files have changed
+	Delay    time.Duration          // Time to wait for further changes before calling OnChange
+	watcher  *fsnotify.Watcher      // File system watcher
+	stop     chan struct{}          // Watcher stop signal
+}
+
+// Start starts watching the directory
+func (w *FileWatcher) Start() error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory rather than the files, so that files replaced
+	// by editors and atomic writes are still seen
+	if err := fw.Add(w.Dir); err != nil {
+		fw.Close()
+		return err
+	}
+	if w.Delay <= 0 {
+		w.Delay = 500 * time.Millisecond
+	}
+	w.watcher = fw
+	w.stop = make(chan struct{})
+	go w.run()
+	return nil
+}
+
+// Stop stops watching the directory
+func (w *FileWatcher) Stop() {
+	if w.watcher == nil {
+		return
+	}
+	close(w.stop)
+	w.watcher.Close()
+	w.watcher = nil
+}
+
+func (w *FileWatcher) run() {
+	t := time.NewTimer(w.Delay)
+	t.Stop()
+	events := w.watcher.Events
+	errs := w.watcher.Errors
+	for {
+		select {
+		case <-w.stop:
+			t.Stop()
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if w.Match == nil || w.Match(filepath.Base(e.Name)) {
+				t.Reset(w.Delay)
+			}
+		case _, ok := <-errs:
+			if !ok {
+				return
+			}
+		case <-t.C:
+			if w.OnChange != nil {
+				w.OnChange()
+			}
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/zcpb"
+)
+
+// GRPCListener serves the gRPC interface on a single configured address
+type GRPCListener struct {
+	Config ListenerConfig // Listener configuration
+	Srv    *Server        // Web Server
+	grpc   *grpc.Server   // gRPC server
+}
+
+// Start starts listening for requests
+func (l *GRPCListener) Start() error {
+	if err := l.Config.Validate(); err != nil {
+		return err
+	}
+	creds := &grpcCredentials{}
+	if l.Config.IsTLS() {
+		tc, err := l.Config.TLSConfig(l.Srv.configFile)
+		if err != nil {
+			return err
+		}
+		creds.tls = credentials.NewTLS(tc)
+	}
+	l.grpc = grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(l.unary),
+		grpc.StreamInterceptor(l.stream),
+	)
+	zcpb.RegisterZCServiceServer(l.grpc, &GRPCService{Srv: l.Srv})
+
+	ln, err := l.Config.Listen()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		l.Srv.logInfo("gRPC server listening", "network", l.Config.NetworkName(), "address", l.Config.Address, "tls", l.Config.IsTLS())
+		if err := l.grpc.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			l.Srv.logError("Error starting gRPC server", "address", l.Config.Address, LogError, err)
+		}
+	}()
+	return nil
+}
+
+// Stop stops listening for requests
+func (l *GRPCListener) Stop() {
+	if l.grpc == nil {
+		return
+	}
+	// Give in-flight requests a moment to complete before closing any
+	// remaining long-lived streams
+	done := make(chan struct{})
+	go func() {
+		l.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		l.grpc.Stop()
+	}
+}
+
+// unary applies the access policy to a request and logs it
+func (l *GRPCListener) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx = l.withRequestID(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", RequestIDFromContext(ctx)))
+	var resp interface{}
+	err := l.authorize(ctx)
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	l.logRequest(ctx, info.FullMethod, err, time.Since(start))
+	return resp, err
+}
+
+// stream applies the access policy to a streaming request and logs it once the stream ends
+func (l *GRPCListener) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := l.withRequestID(ss.Context())
+	ss.SetHeader(metadata.Pairs("x-request-id", RequestIDFromContext(ctx)))
+	err := l.authorize(ctx)
+	if err == nil {
+		err = handler(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+	}
+	l.logRequest(ctx, info.FullMethod, err, time.Since(start))
+	return err
+}
+
+// withRequestID returns a context holding the ID of the request, taken from the x-request-id
+// metadata sent by the client if it is valid, otherwise a new ID
+func (l *GRPCListener) withRequestID(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-request-id"); len(v) != 0 && len(v[0]) <= 64 && !strings.ContainsAny(v[0], " \t\r\n") {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// authorize checks the request against the access policy of the listener
+func (l *GRPCListener) authorize(ctx context.Context) error {
+	addr := ""
+	var owner *api.PeerCred
+	var cs *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+		if ai, ok := p.AuthInfo.(*grpcAuthInfo); ok {
+			owner = ai.Owner
+			if ai.TLS != nil {
+				cs = &ai.TLS.State
+			}
+		}
+	}
+	auth := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) != 0 {
+			auth = v[0]
+		}
+	}
+	switch l.Config.Auth.Check(addr, cs, owner, auth) {
+	case 403:
+		return status.Error(codes.PermissionDenied, "Forbidden")
+	case 401:
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return nil
+}
+
+// logRequest logs the handled request and records it in the gRPC request metrics
+func (l *GRPCListener) logRequest(ctx context.Context, method string, err error, d time.Duration) {
+	code := status.Code(err)
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	componentLog("GRPCService").Info("Request handled", "method", method, "remote", remote,
+		"code", code.String(), "duration", d, LogRequestID, RequestIDFromContext(ctx))
+	grpcRequestsTotal.WithLabelValues(method, code.String()).Inc()
+}
+
+// grpcStream is a server stream with a replaced context
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context // Context of the stream
+}
+
+// Context returns the context of the stream
+func (s *grpcStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuthInfo holds the security details of a gRPC connection
+type grpcAuthInfo struct {
+	credentials.CommonAuthInfo
+	TLS   *credentials.TLSInfo // TLS details, if the connection uses TLS
+	Owner *api.PeerCred        // Peer credentials, if the connection is a Unix socket connection
+}
+
+// AuthType returns the type of security used by the connection
+func (a *grpcAuthInfo) AuthType() string {
+	if a.TLS != nil {
+		return a.TLS.AuthType()
+	}
+	return "insecure"
+}
+
+// grpcCredentials are the transport credentials of the gRPC server.  They add the peer credentials
+// of Unix socket connections to the connection details, so that they can be checked against the
+// access policy in the same way as for the web API.
+type grpcCredentials struct {
+	tls credentials.TransportCredentials // TLS credentials, or nil if TLS is not used
+}
+
+// ClientHandshake is not supported, as the credentials are only used by the server
+func (c *grpcCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("client handshake is not supported")
+}
+
+// ServerHandshake performs the TLS handshake, if TLS is used, and reads the peer credentials of the connection
+func (c *grpcCredentials) ServerHandshake(raw net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	ai := &grpcAuthInfo{CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}}
+	conn := raw
+	if c.tls != nil {
+		tc, tai, err := c.tls.ServerHandshake(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		ti := tai.(credentials.TLSInfo)
+		conn = tc
+		ai.TLS = &ti
+		ai.CommonAuthInfo = ti.CommonAuthInfo
+	}
+	if uc, ok := raw.(*net.UnixConn); ok {
+		if pc, err := getPeerCred(uc); err == nil {
+			ai.Owner = pc
+		}
+	}
+	return conn, ai, nil
+}
+
+// Info returns the protocol details of the credentials
+func (c *grpcCredentials) Info() credentials.ProtocolInfo {
+	if c.tls != nil {
+		return c.tls.Info()
+	}
+	return credentials.ProtocolInfo{SecurityProtocol: "insecure"}
+}
+
+// Clone returns a copy of the credentials
+func (c *grpcCredentials) Clone() credentials.TransportCredentials {
+	n := &grpcCredentials{}
+	if c.tls != nil {
+		n.tls = c.tls.Clone()
+	}
+	return n
+}
+
+// OverrideServerName is not supported, as the credentials are only used by the server
+func (c *grpcCredentials) OverrideServerName(string) error {
+	return nil
+}
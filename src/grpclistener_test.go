@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/Brumawen/zcservice/src/registry"
+	"github.com/Brumawen/zcservice/src/zcpb"
+)
+
+// newGRPCTestServer returns a server that serves the gRPC interface with the access policy,
+// using the same credentials and interceptors as a gRPC listener
+func newGRPCTestServer(t *testing.T, auth AuthPolicy) *GRPCListener {
+	t.Helper()
+	if err := auth.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Registry: registry.New()}
+	s.config.Store(&Config{})
+	t.Cleanup(s.Registry.Close)
+	return &GRPCListener{Config: ListenerConfig{Auth: auth}, Srv: s}
+}
+
+// dialGRPC connects a client to the gRPC target
+func dialGRPC(t *testing.T, target string, opts ...grpc.DialOption) zcpb.ZCServiceClient {
+	t.Helper()
+	conn, err := grpc.NewClient(target, append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return zcpb.NewZCServiceClient(conn)
+}
+
+func TestGRPCAuthPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  AuthPolicy
+		token string
+		code  codes.Code
+	}{
+		{"no policy", AuthPolicy{}, "", codes.NotFound},
+		{"valid token", AuthPolicy{Tokens: []string{"secret"}}, "secret", codes.NotFound},
+		{"missing token", AuthPolicy{Tokens: []string{"secret"}}, "", codes.Unauthenticated},
+		{"wrong token", AuthPolicy{Tokens: []string{"secret"}}, "guess", codes.Unauthenticated},
+		{"address not allowed", AuthPolicy{Allow: []string{"10.0.0.0/8"}}, "", codes.PermissionDenied},
+		{"no peer credentials", AuthPolicy{UIDs: []int{0}}, "", codes.PermissionDenied},
+		{"forbidden before unauthorized", AuthPolicy{Allow: []string{"10.0.0.0/8"}, Tokens: []string{"secret"}}, "", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		l := newGRPCTestServer(t, tt.auth)
+		ln := bufconn.Listen(1024 * 1024)
+		gs := grpc.NewServer(
+			grpc.Creds(&grpcCredentials{}),
+			grpc.UnaryInterceptor(l.unary),
+			grpc.StreamInterceptor(l.stream),
+		)
+		zcpb.RegisterZCServiceServer(gs, &GRPCService{Srv: l.Srv})
+		go gs.Serve(ln)
+
+		c := dialGRPC(t, "passthrough:///bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}))
+		ctx := context.Background()
+		if tt.token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.token)
+		}
+
+		// Unary requests
+		_, err := c.Renew(ctx, &zcpb.RenewRequest{Id: "missing"})
+		if got := status.Code(err); got != tt.code {
+			t.Errorf("%s: Renew() code = %s, want %s", tt.name, got, tt.code)
+		}
+
+		// Streaming requests are refused before the stream starts
+		if tt.code != codes.NotFound {
+			stream, err := c.Watch(ctx, &zcpb.WatchRequest{Request: &zcpb.GetRequest{ServiceType: "_http._tcp"}})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("%s: Watch() code = %s, want %s", tt.name, got, tt.code)
+			}
+		}
+		gs.Stop()
+	}
+}
+
+func TestGRPCPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only read on Linux")
+	}
+	tests := []struct {
+		name string
+		auth AuthPolicy
+		code codes.Code
+	}{
+		{"user allowed", AuthPolicy{UIDs: []int{os.Getuid()}}, codes.OK},
+		{"user and process allowed", AuthPolicy{UIDs: []int{os.Getuid()}, PIDs: []int{os.Getpid()}}, codes.OK},
+		{"user not allowed", AuthPolicy{UIDs: []int{os.Getuid() + 1}}, codes.PermissionDenied},
+		{"group not allowed", AuthPolicy{GIDs: []int{os.Getgid() + 1}}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		l := newGRPCTestServer(t, tt.auth)
+		path := filepath.Join(t.TempDir(), "zcservice.sock")
+		l.Config.Network = "unix"
+		l.Config.Address = path
+		if err := l.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The process connected to the socket is watched instead of the pid in the request
+		c := dialGRPC(t, "unix://"+path)
+		_, err := c.Register(context.Background(), &zcpb.RegisterRequest{Id: "peer", Name: "peer", PortNo: 8080, WatchOwner: true})
+		if got := status.Code(err); got != tt.code {
+			t.Errorf("%s: Register() code = %s, want %s", tt.name, got, tt.code)
+		}
+		z := l.Srv.Registry.Get("peer")
+		if tt.code == codes.OK && (z == nil || z.PID != os.Getpid()) {
+			t.Errorf("%s: registration = %+v, want the pid of the peer %d", tt.name, z, os.Getpid())
+		} else if tt.code != codes.OK && z != nil {
+			t.Errorf("%s: refused request was registered", tt.name)
+		}
+		l.grpc.Stop()
+	}
+}
@@ -0,0 +1,243 @@
+// Package grpcresolver resolves gRPC targets such as "zeroconf:///_orders._grpc._tcp" to the instances of
+// the service type found with zeroconf, either through a zcservice daemon or in-process.  The addresses are
+// updated as instances come and go, and the TXT entries of each instance are carried as attributes for use
+// by load balancers.
+package grpcresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/client"
+	"github.com/Brumawen/zcservice/src/discovery"
+)
+
+// Scheme is the target scheme resolved by the builder
+const Scheme = "zeroconf"
+
+// searchSettle is how long the resolver waits after an event before it sends the addresses to the client
+// connection.  The events of a search arrive together, so this sends the addresses once per search.
+const searchSettle = 500 * time.Millisecond
+
+// TextKey is the attribute key holding the value of a TXT entry of the service instance
+type TextKey string
+
+// instanceKey is the attribute key holding the name of the service instance
+type instanceKey struct{}
+
+// Instance returns the name of the service instance held in the endpoint or address attributes
+func Instance(a *attributes.Attributes) string {
+	v, _ := a.Value(instanceKey{}).(string)
+	return v
+}
+
+// Text returns the value of the TXT entry of the service instance held in the endpoint or address
+// attributes, and whether the instance has the entry
+func Text(a *attributes.Attributes, key string) (string, bool) {
+	v, ok := a.Value(TextKey(key)).(string)
+	return v, ok
+}
+
+// Builder builds resolvers for targets with the zeroconf scheme.  The host of the target is the
+// domain to search, which defaults to "local", and the path is the service type.
+type Builder struct {
+	Client      *client.Client // zcservice daemon used to search for services.  If nil, services are searched for in-process
+	WaitTime    int            // Duration in secs to wait for replies to each search.  Defaults to discovery.DefaultWaitTime
+	Interval    time.Duration  // Interval between searches.  Defaults to discovery.DefaultWatchInterval
+	OnlyHealthy bool           // Only resolve instances that publish a healthy state, or no health state
+}
+
+// NewBuilder creates a builder that searches for services through the zcservice daemon,
+// or in-process if the client is nil
+func NewBuilder(c *client.Client) *Builder {
+	return &Builder{Client: c}
+}
+
+// Register registers a builder for the zeroconf scheme with gRPC, searching for services through
+// the zcservice daemon, or in-process if the client is nil.  As with resolver.Register, it must
+// only be called during initialization.
+func Register(c *client.Client) {
+	resolver.Register(NewBuilder(c))
+}
+
+// Scheme returns the scheme of the targets resolved by the builder
+func (b *Builder) Scheme() string {
+	return Scheme
+}
+
+// Build creates a resolver for the target and starts watching for instances of its service type
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	st := strings.Trim(target.Endpoint(), "/")
+	if st == "" {
+		return nil, errors.New("zeroconf: service type is missing from target " + target.String())
+	}
+	wt := b.WaitTime
+	if wt <= 0 {
+		wt = discovery.DefaultWaitTime
+	}
+	req := api.GetRequest{ServiceType: st, Domain: target.URL.Host, WaitTime: wt, OnlyHealthy: b.OnlyHealthy}
+	req.SetDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &zcResolver{
+		b:        b,
+		cc:       cc,
+		req:      req,
+		cancel:   cancel,
+		services: map[string]api.ServiceItem{},
+	}
+	go r.run(ctx)
+	return r, nil
+}
+
+// zcResolver keeps the addresses of a gRPC client connection up to date with the service instances found
+type zcResolver struct {
+	b        *Builder                   // Builder that created the resolver
+	cc       resolver.ClientConn        // Client connection to update
+	req      api.GetRequest             // Search criteria of the service instances
+	cancel   context.CancelFunc         // Stops watching for service instances
+	services map[string]api.ServiceItem // Service instances found, by name
+	lock     sync.Mutex                 // Mutex lock for services
+}
+
+// ResolveNow does nothing, as the service instances are searched for continuously
+func (r *zcResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+// Close stops watching for service instances
+func (r *zcResolver) Close() {
+	r.cancel()
+}
+
+// run watches for service instances until the resolver is closed.  If the watch fails, or the
+// connection to zcservice is lost, the error is reported and the watch is started again.
+func (r *zcResolver) run(ctx context.Context) {
+	retry := r.b.Interval
+	if retry <= 0 {
+		retry = discovery.DefaultWatchInterval
+	}
+	for {
+		events, err := r.watch(ctx)
+		if err == nil {
+			r.lock.Lock()
+			r.services = map[string]api.ServiceItem{}
+			r.lock.Unlock()
+			r.receive(events)
+			err = errors.New("zeroconf: connection to zcservice was lost")
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		r.cc.ReportError(err)
+
+		t := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// watch starts watching for service instances through the daemon, or in-process if there is no client
+func (r *zcResolver) watch(ctx context.Context) (<-chan api.ServiceEvent, error) {
+	if r.b.Client != nil {
+		return r.b.Client.WatchServices(ctx, r.req, r.b.Interval)
+	}
+	return discovery.Watch(ctx, r.req, r.b.Interval)
+}
+
+// receive applies the service events until the channel is closed, sending the addresses of the service
+// instances to the client connection once each search has finished.  The addresses are sent after the
+// first search even if no instances were found, so that calls fail instead of waiting for an address.
+func (r *zcResolver) receive(events <-chan api.ServiceEvent) {
+	t := time.NewTimer(time.Duration(r.req.WaitTime)*time.Second + searchSettle)
+	defer t.Stop()
+	changed := true
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			r.update(e)
+			changed = true
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+			t.Reset(searchSettle)
+		case <-t.C:
+			if changed {
+				r.sendState()
+				changed = false
+			}
+		}
+	}
+}
+
+// update applies the service event to the service instances found
+func (r *zcResolver) update(e api.ServiceEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if e.Type == api.ServiceRemoved {
+		delete(r.services, e.Service.Name)
+	} else {
+		r.services[e.Service.Name] = e.Service
+	}
+}
+
+// sendState sends the addresses of the service instances found to the client connection
+func (r *zcResolver) sendState() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(r.services))
+	for n := range r.services {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	s := resolver.State{Endpoints: []resolver.Endpoint{}, Addresses: []resolver.Address{}}
+	for _, n := range names {
+		ep := NewEndpoint(r.services[n])
+		if len(ep.Addresses) == 0 {
+			continue
+		}
+		s.Endpoints = append(s.Endpoints, ep)
+		s.Addresses = append(s.Addresses, ep.Addresses...)
+	}
+	r.cc.UpdateState(s)
+}
+
+// NewEndpoint returns the resolver endpoint of the service instance, with an address for each of its IP
+// addresses.  The endpoint and its addresses have attributes holding the instance name and TXT entries.
+func NewEndpoint(i api.ServiceItem) resolver.Endpoint {
+	a := attributes.New(instanceKey{}, i.Name)
+	for _, t := range i.Text {
+		k := api.TextKey(t)
+		a = a.WithValue(TextKey(k), strings.TrimPrefix(t[len(k):], "="))
+	}
+	ep := resolver.Endpoint{Attributes: a}
+	ips := append(append([]net.IP{}, i.AddrIPv4...), i.AddrIPv6...)
+	for _, ip := range ips {
+		ep.Addresses = append(ep.Addresses, resolver.Address{
+			Addr:       net.JoinHostPort(ip.String(), strconv.Itoa(i.Port)),
+			Attributes: a,
+		})
+	}
+	return ep
+}
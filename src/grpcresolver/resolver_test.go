@@ -0,0 +1,174 @@
+package grpcresolver
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/client"
+)
+
+// testClientConn records the states sent by a resolver
+type testClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State // States sent by the resolver
+}
+
+// UpdateState records the state
+func (c *testClientConn) UpdateState(s resolver.State) error {
+	c.states <- s
+	return nil
+}
+
+// ReportError ignores the error, as the resolver retries the watch
+func (c *testClientConn) ReportError(error) {}
+
+// addrs returns the addresses of the state
+func addrs(s resolver.State) []string {
+	l := []string{}
+	for _, a := range s.Addresses {
+		l = append(l, a.Addr)
+	}
+	return l
+}
+
+func TestResolverUpdates(t *testing.T) {
+	events := make(chan api.ServiceEvent)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/service/watch" || r.URL.Query().Get("type") != "_orders._grpc._tcp" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case e := <-events:
+				enc.Encode(e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	a := api.ServiceItem{Name: "a", Port: 9000, AddrIPv4: []net.IP{net.ParseIP("10.0.0.1")}, Text: []string{"zone=east"}}
+	b := api.ServiceItem{Name: "b", Port: 9000, AddrIPv4: []net.IP{net.ParseIP("10.0.0.2")}}
+	cc := &testClientConn{states: make(chan resolver.State, 10)}
+	bld := &Builder{Client: client.New(ts.URL), WaitTime: 1}
+	r, err := bld.Build(resolver.Target{URL: url.URL{Scheme: Scheme, Path: "/_orders._grpc._tcp"}}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// next returns the addresses of the next state sent by the resolver
+	next := func() []string {
+		t.Helper()
+		select {
+		case s := <-cc.states:
+			return addrs(s)
+		case <-time.After(5 * time.Second):
+			t.Fatal("resolver did not send a state")
+			return nil
+		}
+	}
+
+	// The instances found by the first search are sent together
+	events <- api.ServiceEvent{Type: api.ServiceAdded, Service: a}
+	events <- api.ServiceEvent{Type: api.ServiceAdded, Service: b}
+	if got, want := next(), []string{"10.0.0.1:9000", "10.0.0.2:9000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addresses after the instances were added = %v, want %v", got, want)
+	}
+
+	// A removed instance is no longer resolved
+	events <- api.ServiceEvent{Type: api.ServiceRemoved, Service: a}
+	if got, want := next(), []string{"10.0.0.2:9000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addresses after an instance was removed = %v, want %v", got, want)
+	}
+
+	// A changed instance is resolved at its new address
+	b.AddrIPv4 = []net.IP{net.ParseIP("10.0.0.3")}
+	events <- api.ServiceEvent{Type: api.ServiceUpdated, Service: b}
+	if got, want := next(), []string{"10.0.0.3:9000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addresses after an instance changed = %v, want %v", got, want)
+	}
+
+	// Removing the last instance sends an empty state, so that calls fail
+	events <- api.ServiceEvent{Type: api.ServiceRemoved, Service: b}
+	if got := next(); len(got) != 0 {
+		t.Errorf("addresses after all instances were removed = %v, want none", got)
+	}
+}
+
+func TestResolverSendsEmptyState(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	cc := &testClientConn{states: make(chan resolver.State, 10)}
+	bld := &Builder{Client: client.New(ts.URL), WaitTime: 1}
+	r, err := bld.Build(resolver.Target{URL: url.URL{Scheme: Scheme, Path: "/_orders._grpc._tcp"}}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The first search finds nothing, which is sent so that calls fail instead of waiting for an address
+	select {
+	case s := <-cc.states:
+		if len(s.Addresses) != 0 {
+			t.Errorf("addresses = %v, want none", addrs(s))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolver did not send a state after the first search")
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	i := api.ServiceItem{
+		Name:     "orders-1",
+		Port:     9000,
+		AddrIPv4: []net.IP{net.ParseIP("10.0.0.1")},
+		AddrIPv6: []net.IP{net.ParseIP("fd00::1")},
+		Text:     []string{"zone=east", "canary", "weight="},
+	}
+	ep := NewEndpoint(i)
+	got := []string{}
+	for _, a := range ep.Addresses {
+		got = append(got, a.Addr)
+	}
+	if want := []string{"10.0.0.1:9000", "[fd00::1]:9000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addresses = %v, want %v", got, want)
+	}
+	if n := Instance(ep.Attributes); n != "orders-1" {
+		t.Errorf("Instance() = %q, want %q", n, "orders-1")
+	}
+	for _, tt := range []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"zone", "east", true},
+		{"canary", "", true},
+		{"weight", "", true},
+		{"region", "", false},
+	} {
+		if v, ok := Text(ep.Addresses[0].Attributes, tt.key); v != tt.value || ok != tt.ok {
+			t.Errorf("Text(%q) = %q, %v, want %q, %v", tt.key, v, ok, tt.value, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
+	"github.com/Brumawen/zcservice/src/zcpb"
+)
+
+// GRPCService implements the zcservice gRPC interface, mirroring the ServiceController web methods
+type GRPCService struct {
+	zcpb.UnimplementedZCServiceServer
+	Srv *Server // Web Server
+}
+
+// Register registers a service, or confirms an existing registration with the same id
+func (g *GRPCService) Register(ctx context.Context, m *zcpb.RegisterRequest) (*zcpb.RegisterResponse, error) {
+	req := m.ToAPI()
+	resp, err := g.Srv.RegisterService(&req, CallerFromContext(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &zcpb.RegisterResponse{Id: resp.ID}, nil
+}
+
+// Renew confirms a registration without sending it again
+func (g *GRPCService) Renew(ctx context.Context, m *zcpb.RenewRequest) (*zcpb.RegisterResponse, error) {
+	if !g.Srv.Registry.Renew(m.GetId(), CallerFromContext(ctx)) {
+		return nil, status.Error(codes.NotFound, "Service not found.")
+	}
+	return &zcpb.RegisterResponse{Id: m.GetId()}, nil
+}
+
+// Deregister removes a service registration
+func (g *GRPCService) Deregister(ctx context.Context, m *zcpb.DeregisterRequest) (*zcpb.DeregisterResponse, error) {
+	if err := g.Srv.CheckDeregister(m.GetId()); err != nil {
+		return nil, grpcError(err)
+	}
+	g.Srv.Registry.Deregister(m.GetId(), registry.ReasonRequested, CallerFromContext(ctx))
+	return &zcpb.DeregisterResponse{}, nil
+}
+
+// Browse searches the network for services of a type
+func (g *GRPCService) Browse(ctx context.Context, m *zcpb.GetRequest) (*zcpb.GetResponse, error) {
+	req := m.ToAPI()
+	req.SetDefaults()
+	resp, err := g.Srv.GetServiceList(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return zcpb.NewGetResponse(&resp), nil
+}
+
+// Lookup searches the network for a single service instance
+func (g *GRPCService) Lookup(ctx context.Context, m *zcpb.LookupRequest) (*zcpb.ServiceItem, error) {
+	req := api.GetRequest{ServiceType: m.GetServiceType(), Domain: m.GetDomain(), WaitTime: int(m.GetWaitTime())}
+	req.SetDefaults()
+	resp, err := g.Srv.GetServiceList(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	i := resp.Find(m.GetName())
+	if i == nil {
+		return nil, status.Error(codes.NotFound, "Service not found.")
+	}
+	return zcpb.NewServiceItem(i), nil
+}
+
+// Watch streams an event each time a service instance is found, changes or is no longer found
+func (g *GRPCService) Watch(m *zcpb.WatchRequest, stream zcpb.ZCService_WatchServer) error {
+	if m.GetInterval() < 0 {
+		return status.Error(codes.InvalidArgument, "Invalid Interval.")
+	}
+	req := m.GetRequest().ToAPI()
+	req.SetDefaults()
+	events, err := g.Srv.WatchServices(stream.Context(), req, time.Duration(m.GetInterval())*time.Second)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	for e := range events {
+		if err := stream.Send(zcpb.NewServiceEvent(&e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CallerFromContext returns the caller details of the gRPC request
+func CallerFromContext(ctx context.Context) registry.Caller {
+	c := registry.Caller{RequestID: RequestIDFromContext(ctx)}
+	if p, ok := peer.FromContext(ctx); ok {
+		c.Addr = p.Addr.String()
+		if ai, ok := p.AuthInfo.(*grpcAuthInfo); ok {
+			c.Owner = ai.Owner
+		}
+	}
+	return c
+}
+
+// grpcError returns the gRPC status error for the refused request
+func grpcError(e *RequestError) error {
+	code := codes.Unknown
+	switch e.Status {
+	case 400:
+		code = codes.InvalidArgument
+	case 401:
+		code = codes.Unauthenticated
+	case 403:
+		code = codes.PermissionDenied
+	case 404:
+		code = codes.NotFound
+	case 409:
+		code = codes.AlreadyExists
+	}
+	return status.Error(code, e.Message)
+}
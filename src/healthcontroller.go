@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// HealthController handles the web methods for checking the liveness and readiness of the service
+type HealthController struct {
+	Srv *Server
+}
+
+// AddController adds the controller routes to the router
+func (c *HealthController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	router.Methods("GET").Path("/health/live").
+		Handler(Logger(c, http.HandlerFunc(c.handleLive)))
+	router.Methods("GET").Path("/health/ready").
+		Handler(Logger(c, http.HandlerFunc(c.handleReady)))
+}
+
+// handleLive handles the /health/live web method call
+func (c *HealthController) handleLive(w http.ResponseWriter, r *http.Request) {
+	resp := c.Srv.GetLiveness()
+	resp.WriteTo(w)
+}
+
+// handleReady handles the /health/ready web method call
+func (c *HealthController) handleReady(w http.ResponseWriter, r *http.Request) {
+	resp := c.Srv.GetReadiness()
+	resp.WriteTo(w)
+}
+
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *HealthController) LogInfo(msg string, args ...interface{}) {
+	componentLog("HealthController").Info(msg, args...)
+}
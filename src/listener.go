@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListenerConfig defines an address the web server listens on
+type ListenerConfig struct {
+	Network      string     `json:"network,omitempty"`      // Network type, either "tcp" or "unix".  Defaults to "tcp"
+	Address      string     `json:"address"`                // Address to listen on, in host:port format, or the socket path for "unix"
+	Mode         string     `json:"mode,omitempty"`         // File mode of the Unix socket in octal.  Defaults to "0660"
+	CertFile     string     `json:"certFile,omitempty"`     // TLS certificate file.  If blank, TLS is not used
+	KeyFile      string     `json:"keyFile,omitempty"`      // TLS private key file
+	ClientCAFile string     `json:"clientCAFile,omitempty"` // CA certificates used to verify client certificates.  If blank, client certificates are not required
+	Auth         AuthPolicy `json:"auth"`                   // Access policy applied to requests received on this listener
+}
+
+// Validate checks the listener configuration values
+func (c *ListenerConfig) Validate() error {
+	if c.Address == "" {
+		return errors.New("listener address is missing")
+	}
+	switch c.Network {
+	case "", "tcp":
+	case "unix":
+		if _, err := c.FileMode(); err != nil {
+			return fmt.Errorf("listener %s: invalid mode '%s'", c.Address, c.Mode)
+		}
+	default:
+		return fmt.Errorf("listener %s: invalid network '%s'", c.Address, c.Network)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("listener %s: both certFile and keyFile must be specified", c.Address)
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		return fmt.Errorf("listener %s: clientCAFile requires certFile and keyFile", c.Address)
+	}
+	if err := c.Auth.Validate(); err != nil {
+		return fmt.Errorf("listener %s: %s", c.Address, err.Error())
+	}
+	return nil
+}
+
+// NetworkName returns the network type of the listener
+func (c *ListenerConfig) NetworkName() string {
+	if c.Network == "" {
+		return "tcp"
+	}
+	return c.Network
+}
+
+// FileMode returns the file mode to apply to a Unix socket
+func (c *ListenerConfig) FileMode() (os.FileMode, error) {
+	if c.Mode == "" {
+		return 0660, nil
+	}
+	m, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return os.FileMode(m), nil
+}
+
+// IsTLS returns whether the listener serves TLS
+func (c *ListenerConfig) IsTLS() bool {
+	return c.CertFile != ""
+}
+
+// TLSConfig returns the TLS configuration of the listener, loading the certificate and client CA files.
+// The file function returns the path of a file named in the configuration.
+func (c *ListenerConfig) TLSConfig(file func(string) string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(file(c.CertFile), file(c.KeyFile))
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if c.ClientCAFile != "" {
+		b, err := ioutil.ReadFile(file(c.ClientCAFile))
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tc, nil
+}
+
+// Listen opens the network listener
+func (c *ListenerConfig) Listen() (net.Listener, error) {
+	if c.NetworkName() != "unix" {
+		return net.Listen("tcp", c.Address)
+	}
+
+	// Remove a stale socket left behind by a previous instance
+	if fi, err := os.Lstat(c.Address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(c.Address)
+	}
+	ln, err := net.Listen("unix", c.Address)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := c.FileMode()
+	if err := os.Chmod(c.Address, m); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Listener serves the web server router on a single configured address
+type Listener struct {
+	Config ListenerConfig // Listener configuration
+	Srv    *Server        // Web Server
+	http   *http.Server   // HTTP server
+}
+
+// Start starts listening for requests
+func (l *Listener) Start() error {
+	if err := l.Config.Validate(); err != nil {
+		return err
+	}
+	l.http = &http.Server{
+		Addr:        l.Config.Address,
+		Handler:     l.Config.Auth.Handler(l.Srv.router),
+		ConnContext: withPeerCred,
+	}
+	if l.Config.IsTLS() {
+		tc, err := l.Config.TLSConfig(l.Srv.configFile)
+		if err != nil {
+			return err
+		}
+		l.http.TLSConfig = tc
+	}
+
+	ln, err := l.Config.Listen()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		var err error
+		if l.Config.IsTLS() {
+			l.Srv.logInfo("Server listening", "network", l.Config.NetworkName(), "address", l.Config.Address, "tls", true)
+			err = l.http.ServeTLS(ln, "", "")
+		} else {
+			l.Srv.logInfo("Server listening", "network", l.Config.NetworkName(), "address", l.Config.Address)
+			err = l.http.Serve(ln)
+		}
+		if err != nil {
+			msg := err.Error()
+			if !strings.Contains(msg, "http: Server closed") {
+				l.Srv.logError("Error starting Web Server", "address", l.Config.Address, LogError, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops listening for requests
+func (l *Listener) Stop() {
+	if l.http == nil {
+		return
+	}
+	// Give in-flight requests a moment to complete before closing any
+	// remaining long-lived connections, such as event streams
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.http.Shutdown(ctx); err != nil {
+		l.http.Close()
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+// Log field names shared by all components
+const (
+	LogComponent      = registry.LogComponent      // Name of the component writing the log entry
+	LogRegistrationID = registry.LogRegistrationID // ID of the service registration
+	LogServiceType    = registry.LogServiceType    // Service Type
+	LogRequestID      = registry.LogRequestID      // ID of the HTTP request
+	LogError          = registry.LogError          // Error message
+)
+
+var (
+	logLevel  = new(slog.LevelVar)                               // Current log level, can be changed at runtime
+	appLog    = slog.New(newServiceLogHandler("text", logLevel)) // Structured logger used by all components
+	logFormat = "text"                                           // Current log output format
+	logLock   sync.Mutex                                         // Mutex lock for changing the log format
+)
+
+// ConfigureLog sets the log level and output format.
+// Valid levels are "debug", "info", "warn" and "error".  Valid formats are "text" and "json".
+func ConfigureLog(level string, format string) error {
+	if err := SetLogLevel(level); err != nil {
+		return err
+	}
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid log format '%s'", format)
+	}
+	logLock.Lock()
+	defer logLock.Unlock()
+	if format != logFormat {
+		logFormat = format
+		appLog = slog.New(newServiceLogHandler(format, logLevel))
+	}
+	return nil
+}
+
+// SetLogLevel changes the log level
+func SetLogLevel(level string) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(l)
+	return nil
+}
+
+// GetLogLevel returns the name of the current log level
+func GetLogLevel() string {
+	return strings.ToLower(logLevel.Level().String())
+}
+
+// componentLog returns the logger for the named component
+func componentLog(name string) *slog.Logger {
+	logLock.Lock()
+	defer logLock.Unlock()
+	return appLog.With(LogComponent, name)
+}
+
+// parseLogLevel converts a level name into a log level
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("invalid log level '%s'", level)
+}
+
+// serviceLogHandler formats log records as text or json and forwards them to the service logger
+type serviceLogHandler struct {
+	inner slog.Handler  // Handler used to format the records
+	buf   *bytes.Buffer // Buffer the formatted record is written to
+	lock  *sync.Mutex   // Mutex lock for the buffer
+}
+
+// newServiceLogHandler creates a new handler with the specified format and level
+func newServiceLogHandler(format string, level slog.Leveler) *serviceLogHandler {
+	h := &serviceLogHandler{
+		buf:  new(bytes.Buffer),
+		lock: new(sync.Mutex),
+	}
+	if format == "json" {
+		h.inner = slog.NewJSONHandler(h.buf, &slog.HandlerOptions{
+			Level:       level,
+			ReplaceAttr: dropEmptyAttr,
+		})
+	} else {
+		// The service logger adds its own timestamp
+		h.inner = slog.NewTextHandler(h.buf, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return dropEmptyAttr(groups, a)
+			},
+		})
+	}
+	return h
+}
+
+// dropEmptyAttr leaves out fields with blank values, such as a missing request ID
+func dropEmptyAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindString && a.Value.String() == "" {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// Enabled reports whether the handler handles records at the given level
+func (h *serviceLogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+// Handle formats the record and forwards it to the service logger
+func (h *serviceLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.lock.Lock()
+	h.buf.Reset()
+	err := h.inner.Handle(ctx, r)
+	line := strings.TrimRight(h.buf.String(), "\n")
+	h.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if logger == nil {
+		fmt.Println(line)
+		return nil
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+		return logger.Error(line)
+	case r.Level >= slog.LevelWarn:
+		return logger.Warning(line)
+	default:
+		return logger.Info(line)
+	}
+}
+
+// WithAttrs returns a new handler whose records include the specified attributes
+func (h *serviceLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &serviceLogHandler{inner: h.inner.WithAttrs(attrs), buf: h.buf, lock: h.lock}
+}
+
+// WithGroup returns a new handler that qualifies later attributes with the group name
+func (h *serviceLogHandler) WithGroup(name string) slog.Handler {
+	return &serviceLogHandler{inner: h.inner.WithGroup(name), buf: h.buf, lock: h.lock}
+}
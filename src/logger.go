@@ -1,15 +1,84 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
 )
 
+// requestIDKey is the context key used to store the ID of a http request
+type requestIDKey struct{}
+
 // Logger will create a Logger Handler wrapper for the specified handler.
+// Each request is given a request ID, which is returned in the X-Request-ID header,
+// and the request is recorded in the HTTP request metrics.
 func Logger(c Controller, inner http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		inner.ServeHTTP(w, r)
-		c.LogInfo(r.Method, r.RequestURI, "from", r.RemoteAddr, "took", time.Since(start))
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 || strings.ContainsAny(id, " \t\r\n") {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		sw := &statusWriter{ResponseWriter: w, status: 200}
+		inner.ServeHTTP(sw, r)
+		d := time.Since(start)
+		c.LogInfo("Request handled", "method", r.Method, "uri", r.RequestURI, "remote", r.RemoteAddr,
+			"status", sw.status, "duration", d, LogRequestID, id)
+
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if t, err := cr.GetPathTemplate(); err == nil {
+				route = t
+			}
+		}
+		httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(d.Seconds())
 	})
 }
+
+// RequestIDFromContext returns the ID of the http request stored in the context, or blank if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID generates a new unique request ID
+func newRequestID() string {
+	if u, err := uuid.NewV4(); err == nil {
+		return strings.Replace(u.String(), "-", "", -1)
+	}
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// statusWriter records the status code written to a http response
+type statusWriter struct {
+	http.ResponseWriter
+	status int // Status code written
+}
+
+// WriteHeader records the status code and writes it to the response
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the wrapped response writer, so that http.ResponseController can
+// reach its Hijack method, e.g. when a proxied request is upgraded to a WebSocket
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush sends any buffered data to the client
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/kardianos/service"
@@ -11,23 +14,42 @@ import (
 
 var logger service.Logger
 
+// version is the version of zcservice.  It is set at build time using
+// -ldflags "-X main.version=x.y.z"
+var version = "dev"
+
 func main() {
+	// Run a command line subcommand, e.g. "zcservice browse _http._tcp"
+	if len(os.Args) > 1 && isCommand(os.Args[1]) {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	port := flag.Int("p", 20404, "Port number to listen on.")
 	svcFlag := flag.String("service", "", "Service action.  Valid actions are: 'start', 'stop', 'restart', 'install' and 'uninstall'")
 	waitTime := flag.Int("wait", 2, "Duration in secs to wait for responses when discovering services.")
+	cfgPath := flag.String("config", os.Getenv("ZCSERVICE_CONFIG"), "Path of the configuration file.  Can also be set with the ZCSERVICE_CONFIG environment variable.")
+	stateDir := flag.String("state", os.Getenv("ZCSERVICE_STATE_DIR"), "Folder to keep state files in.  Can also be set with the ZCSERVICE_STATE_DIR environment variable.")
+	checkCfg := flag.Bool("check-config", false, "Check the configuration file for errors and exit.")
 	flag.Parse()
 
+	if *checkCfg {
+		os.Exit(checkConfig(*cfgPath))
+	}
+
 	// Create the web server
 	s := &Server{
-		PortNo:   *port,
-		WaitTime: *waitTime,
+		PortNo:     *port,
+		WaitTime:   *waitTime,
+		ConfigPath: *cfgPath,
+		StateDir:   *stateDir,
 	}
 
-	// Create the service
+	// Create the service, passing the run flags through to the installed service
 	svcConfig := &service.Config{
 		Name:        "zcservice",
 		DisplayName: "Zeroconf Service",
 		Description: "Provides registration and client services for zeroconf/ bonjour",
+		Arguments:   runArguments(),
 	}
 	v, err := service.New(s, svcConfig)
 	if err != nil {
@@ -70,3 +92,59 @@ func main() {
 	}
 
 }
+
+// checkConfig checks the configuration file and the service files in the services folder,
+// printing every error found in them, and returns the exit code
+func checkConfig(path string) int {
+	if path == "" {
+		path = DefaultConfigPath()
+	}
+	code := 0
+	if err := CheckConfigFile(path); err != nil {
+		printConfigErrors(path, err)
+		code = 1
+	} else {
+		fmt.Println(path + ": configuration is valid")
+	}
+	errs := CheckServiceFiles(path)
+	files := make([]string, 0, len(errs))
+	for p := range errs {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	for _, p := range files {
+		printConfigErrors(p, errs[p])
+		code = 1
+	}
+	return code
+}
+
+// printConfigErrors prints the errors found in the file, one per line
+func printConfigErrors(path string, err error) {
+	if errs, ok := err.(ConfigErrors); ok {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, path+": "+e.Error())
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+// runArguments returns the command line flags, other than -service and -check-config, that were set,
+// so that the installed service runs with the same settings
+func runArguments() []string {
+	args := []string{}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "service" || f.Name == "check-config" {
+			return
+		}
+		v := f.Value.String()
+		if f.Name == "config" || f.Name == "state" {
+			if p, err := filepath.Abs(v); err == nil {
+				v = p
+			}
+		}
+		args = append(args, "-"+f.Name, v)
+	})
+	return args
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	registrationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_registrations_total",
+		Help: "Number of service registrations.",
+	}, []string{"service_type"})
+
+	deregistrationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_deregistrations_total",
+		Help: "Number of service deregistrations by reason.",
+	}, []string{"service_type", "reason"})
+
+	expiriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_expiries_total",
+		Help: "Number of service registrations removed automatically because they expired.",
+	}, []string{"service_type", "reason"})
+
+	browseTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_browse_total",
+		Help: "Number of service browse requests.",
+	}, []string{"service_type"})
+
+	browseDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "zcservice_browse_duration_seconds",
+		Help:    "Duration of service browse requests.",
+		Buckets: []float64{0.5, 1, 2, 3, 5, 10, 20, 30},
+	})
+
+	browseResults = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "zcservice_browse_results",
+		Help:    "Number of services found by service browse requests.",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+
+	mdnsErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_mdns_errors_total",
+		Help: "Number of mDNS errors by operation.",
+	}, []string{"operation"})
+
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_http_requests_total",
+		Help: "Number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "zcservice_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	grpcRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_grpc_requests_total",
+		Help: "Number of gRPC requests by method and status code.",
+	}, []string{"method", "code"})
+
+	proxyRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_proxy_requests_total",
+		Help: "Number of proxied requests by service type and status code.",
+	}, []string{"service_type", "status"})
+
+	proxyRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "zcservice_proxy_retries_total",
+		Help: "Number of proxied requests sent to another instance because an instance could not be reached.",
+	}, []string{"service_type"})
+)
+
+// registrationCollector reports the active registrations held by the server
+type registrationCollector struct {
+	srv  *Server
+	desc *prometheus.Desc
+}
+
+// newRegistrationCollector creates a collector for the active registrations of the server
+func newRegistrationCollector(s *Server) *registrationCollector {
+	return &registrationCollector{
+		srv: s,
+		desc: prometheus.NewDesc("zcservice_registrations_active",
+			"Number of active service registrations by service type.",
+			[]string{"service_type"}, nil),
+	}
+}
+
+// Describe sends the metric descriptions to the channel
+func (c *registrationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect sends the current registration counts to the channel
+func (c *registrationCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := make(map[string]int)
+	for _, i := range c.srv.Registry.List().Services {
+		counts[i.ServiceType]++
+	}
+	for t, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), t)
+	}
+}
+
+// newMetricsRegistry creates the registry holding the zcservice metrics for the server
+func newMetricsRegistry(s *Server) *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		newRegistrationCollector(s),
+		registrationsTotal,
+		deregistrationsTotal,
+		expiriesTotal,
+		browseTotal,
+		browseDuration,
+		browseResults,
+		mdnsErrorsTotal,
+		httpRequestsTotal,
+		httpRequestDuration,
+		grpcRequestsTotal,
+		proxyRequestsTotal,
+		proxyRetriesTotal,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return r
+}
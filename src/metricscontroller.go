@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/discovery"
+)
+
+// MetricsController handles the web methods for exposing Prometheus metrics
+type MetricsController struct {
+	Srv *Server
+}
+
+// AddController adds the controller routes to the router
+func (c *MetricsController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	h := promhttp.HandlerFor(newMetricsRegistry(s), promhttp.HandlerOpts{})
+	router.Methods("GET").Path("/metrics").
+		Handler(Logger(c, h))
+	router.Methods("GET").Path("/sd/prometheus").
+		Handler(Logger(c, http.HandlerFunc(c.handleSD)))
+}
+
+// handleSD handles the /sd/prometheus web method call, returning the instances of a service type
+// as Prometheus HTTP service discovery targets
+func (c *MetricsController) handleSD(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := api.GetRequest{
+		ServiceType: q.Get("type"),
+		Domain:      q.Get("domain"),
+		OnlyHealthy: q.Get("onlyHealthy") == "true",
+	}
+	req.SetDefaults()
+	if req.ServiceType == "" {
+		http.Error(w, "Service type is missing.", 400)
+		return
+	}
+	if !isServiceType(req.ServiceType) {
+		http.Error(w, "Invalid Service Type.", 400)
+		return
+	}
+	resp, err := c.Srv.GetServiceList(req)
+	if err != nil {
+		// Only a failure to search the network is an error on our side
+		var de *discovery.Error
+		if errors.As(err, &de) {
+			http.Error(w, err.Error(), 500)
+		} else {
+			http.Error(w, err.Error(), 400)
+		}
+		return
+	}
+	b, err := json.Marshal(NewPrometheusTargets(resp.Services))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Write(b)
+}
+
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *MetricsController) LogInfo(msg string, args ...interface{}) {
+	componentLog("MetricsController").Info(msg, args...)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -31,8 +30,7 @@ func (c *OnlineController) handleOnline(w http.ResponseWriter, r *http.Request)
 // 	c.Srv.Shutdown()
 // }
 
-// LogInfo is used to log information messages for this controller.
-func (c *OnlineController) LogInfo(v ...interface{}) {
-	a := fmt.Sprint(v)
-	logger.Info("OnlineController: [Inf] ", a[1:len(a)-1])
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *OnlineController) LogInfo(msg string, args ...interface{}) {
+	componentLog("OnlineController").Info(msg, args...)
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDirName is the name of the folder zcservice keeps its files in
+const appDirName = "zcservice"
+
+// DefaultConfigPath returns the path of the configuration file to use when none is specified.
+// An existing configuration file next to the executable is used for compatibility with earlier
+// installs, otherwise the platform default location is used:
+//
+//   - Windows: %ProgramData%\zcservice\config.json
+//   - Unix, running as root: /etc/zcservice/config.json
+//   - Unix, running as a user: $XDG_CONFIG_HOME/zcservice/config.json
+//
+// In each folder, an existing config.yaml, config.yml or config.toml file is used instead
+// of config.json if there is no config.json file.
+func DefaultConfigPath() string {
+	if ap, err := os.Executable(); err == nil {
+		if p := findConfigFile(filepath.Dir(ap)); p != "" {
+			return p
+		}
+	}
+	dir := ""
+	switch {
+	case runtime.GOOS == "windows":
+		dir = filepath.Join(programDataDir(), appDirName)
+	case os.Geteuid() == 0:
+		dir = filepath.Join("/etc", appDirName)
+	default:
+		if d, err := os.UserConfigDir(); err == nil {
+			dir = filepath.Join(d, appDirName)
+		}
+	}
+	if p := findConfigFile(dir); p != "" {
+		return p
+	}
+	return filepath.Join(dir, configFileNames[0])
+}
+
+// findConfigFile returns the path of the first configuration file that exists in the folder, or blank if there is none
+func findConfigFile(dir string) string {
+	for _, n := range configFileNames {
+		p := filepath.Join(dir, n)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// DefaultStateDir returns the folder to keep state files in, such as the audit log,
+// when none is specified:
+//
+//   - Windows: %ProgramData%\zcservice
+//   - Unix, running as root: /var/lib/zcservice
+//   - Unix, running as a user: $XDG_STATE_HOME/zcservice
+func DefaultStateDir() string {
+	switch {
+	case runtime.GOOS == "windows":
+		return filepath.Join(programDataDir(), appDirName)
+	case os.Geteuid() == 0:
+		return filepath.Join("/var/lib", appDirName)
+	}
+	if d := os.Getenv("XDG_STATE_HOME"); d != "" {
+		return filepath.Join(d, appDirName)
+	}
+	if d, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(d, ".local", "state", appDirName)
+	}
+	return "."
+}
+
+// resolvePath returns the path relative to the specified folder, unless it is blank or absolute
+func resolvePath(dir string, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// programDataDir returns the Windows ProgramData folder
+func programDataDir() string {
+	if d := os.Getenv("ProgramData"); d != "" {
+		return d
+	}
+	return `C:\ProgramData`
+}
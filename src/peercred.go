@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// peerCredKey is the context key used to store the peer credentials of a connection
+type peerCredKey struct{}
+
+// withPeerCred returns a context holding the peer credentials of the specified
+// connection, if the connection is a Unix socket connection
+func withPeerCred(ctx context.Context, c net.Conn) context.Context {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	pc, err := getPeerCred(uc)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, peerCredKey{}, pc)
+}
+
+// PeerCredFromContext returns the peer credentials stored in the context, or nil if there are none
+func PeerCredFromContext(ctx context.Context) *api.PeerCred {
+	pc, _ := ctx.Value(peerCredKey{}).(*api.PeerCred)
+	return pc
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// getPeerCred returns the SO_PEERCRED credentials of the Unix socket connection
+func getPeerCred(c *net.UnixConn) (*api.PeerCred, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var uc *syscall.Ucred
+	var uerr error
+	err = rc.Control(func(fd uintptr) {
+		uc, uerr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if uerr != nil {
+		return nil, uerr
+	}
+	return &api.PeerCred{PID: int(uc.Pid), UID: int(uc.Uid), GID: int(uc.Gid)}, nil
+}
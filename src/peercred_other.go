@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// getPeerCred returns the credentials of the Unix socket connection.
+// Peer credentials are only supported on Linux.
+func getPeerCred(c *net.UnixConn) (*api.PeerCred, error) {
+	return nil, errors.New("peer credentials are not supported on this platform")
+}
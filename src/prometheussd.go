@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/discovery"
+)
+
+// prometheusWriteDelay is the time to wait for further changes before a file_sd file is written,
+// as the changes found by a search are reported one at a time
+const prometheusWriteDelay = 500 * time.Millisecond
+
+// PrometheusConfig defines the Prometheus service discovery settings
+type PrometheusConfig struct {
+	Files []PrometheusFileConfig `json:"files,omitempty"` // file_sd files to keep up to date with the services found
+}
+
+// PrometheusFileConfig defines a Prometheus file_sd file holding the instances of a service type
+type PrometheusFileConfig struct {
+	Path        string `json:"path"`                  // Path of the file.  Relative paths are relative to the state folder
+	ServiceType string `json:"serviceType"`           // Service type to search for
+	Domain      string `json:"domain,omitempty"`      // Domain to search.  Defaults to "local"
+	OnlyHealthy bool   `json:"onlyHealthy,omitempty"` // Only include instances that publish a healthy state, or no health state
+	Interval    int    `json:"interval,omitempty"`    // Interval between searches in secs.  Defaults to 30
+}
+
+// Validate checks the file_sd file configuration values for errors
+func (c *PrometheusFileConfig) Validate() error {
+	if c.Path == "" {
+		return errors.New("path is missing")
+	}
+	if c.ServiceType == "" {
+		return errors.New("serviceType is missing")
+	}
+	if !isServiceType(c.ServiceType) {
+		return errors.New("invalid serviceType '" + c.ServiceType + "'")
+	}
+	if c.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	return nil
+}
+
+// isServiceType returns whether the value is a service type, e.g. "_http._tcp"
+func isServiceType(t string) bool {
+	l := strings.Split(strings.TrimSuffix(t, "."), ".")
+	if len(l) != 2 || (l[1] != "_tcp" && l[1] != "_udp") {
+		return false
+	}
+	return len(l[0]) > 1 && strings.HasPrefix(l[0], "_")
+}
+
+// prometheusTargetGroup is a group of scrape targets in the Prometheus HTTP SD and file_sd formats
+type prometheusTargetGroup struct {
+	Targets []string          `json:"targets"` // Addresses of the targets in host:port format
+	Labels  map[string]string `json:"labels"`  // Labels of the targets
+}
+
+// NewPrometheusTargets returns a target group for each service instance that has an address, sorted
+// by instance name.  The TXT entries of each instance become __meta_zeroconf_{key} labels.
+func NewPrometheusTargets(services []api.ServiceItem) []prometheusTargetGroup {
+	l := append([]api.ServiceItem{}, services...)
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	groups := []prometheusTargetGroup{}
+	for _, i := range l {
+		addr := prometheusTarget(i)
+		if addr == "" {
+			continue
+		}
+		labels := map[string]string{}
+		for _, t := range i.Text {
+			k := api.TextKey(t)
+			if k == "" {
+				continue
+			}
+			v := "true"
+			if k != t {
+				v = t[len(k)+1:]
+			}
+			labels["__meta_zeroconf_"+prometheusLabelName(k)] = v
+		}
+		labels["__meta_zeroconf_instance"] = i.Name
+		labels["__meta_zeroconf_service_type"] = i.Service
+		labels["__meta_zeroconf_domain"] = i.Domain
+		labels["__meta_zeroconf_hostname"] = strings.TrimSuffix(i.HostName, ".")
+		groups = append(groups, prometheusTargetGroup{Targets: []string{addr}, Labels: labels})
+	}
+	return groups
+}
+
+// prometheusTarget returns the host:port address of the service instance, preferring IPv4,
+// or blank if it has no address
+func prometheusTarget(i api.ServiceItem) string {
+	host := strings.TrimSuffix(i.HostName, ".")
+	if len(i.AddrIPv4) != 0 {
+		host = i.AddrIPv4[0].String()
+	} else if len(i.AddrIPv6) != 0 {
+		host = i.AddrIPv6[0].String()
+	}
+	if host == "" || i.Port <= 0 {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(i.Port))
+}
+
+// prometheusLabelName returns the TXT key as a valid label name, in lower case with any
+// characters other than letters, digits and underscores replaced by underscores
+func prometheusLabelName(k string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(k))
+}
+
+// PrometheusFileWriter keeps a Prometheus file_sd file up to date with the instances of a service type
+type PrometheusFileWriter struct {
+	Config PrometheusFileConfig // File configuration
+	Path   string               // Path of the file
+	Srv    *Server              // Web Server
+	cancel context.CancelFunc   // Stops watching for services
+	done   chan struct{}        // Closed once the writer has stopped
+}
+
+// Start starts watching for instances of the service type and writing them to the file
+func (w *PrometheusFileWriter) Start() error {
+	if err := w.Config.Validate(); err != nil {
+		return err
+	}
+	interval := w.Config.Interval
+	if interval <= 0 {
+		interval = 30
+	}
+	req := api.GetRequest{ServiceType: w.Config.ServiceType, Domain: w.Config.Domain, OnlyHealthy: w.Config.OnlyHealthy}
+	req.SetDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := w.Srv.WatchServices(ctx, req, time.Duration(interval)*time.Second)
+	if err != nil {
+		cancel()
+		return err
+	}
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.run(events)
+	return nil
+}
+
+// Stop stops watching for services.  The file is left as it is.
+func (w *PrometheusFileWriter) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+}
+
+// run applies the service events and writes the file once each batch of changes is complete
+func (w *PrometheusFileWriter) run(events <-chan api.ServiceEvent) {
+	defer close(w.done)
+	services := map[string]api.ServiceItem{}
+	var last []byte
+	// Wait for the first search to complete before writing the file, so that it is not emptied on start up
+	wt := w.Srv.WaitTime
+	if wt <= 0 {
+		wt = discovery.DefaultWaitTime
+	}
+	t := time.NewTimer(time.Duration(wt)*time.Second + prometheusWriteDelay)
+	defer t.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if e.Type == api.ServiceRemoved {
+				delete(services, e.Service.Name)
+			} else {
+				services[e.Service.Name] = e.Service
+			}
+			if !t.Stop() {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+			t.Reset(prometheusWriteDelay)
+		case <-t.C:
+			l := make([]api.ServiceItem, 0, len(services))
+			for _, i := range services {
+				l = append(l, i)
+			}
+			b, err := json.MarshalIndent(NewPrometheusTargets(l), "", "  ")
+			if err != nil || bytes.Equal(b, last) {
+				continue
+			}
+			if last == nil {
+				// Don't rewrite the file on start up if it already holds the same targets
+				if o, err := ioutil.ReadFile(w.Path); err == nil && bytes.Equal(o, b) {
+					last = b
+					continue
+				}
+			}
+			if err := writeFileAtomic(w.Path, b, 0644); err != nil {
+				w.Srv.logError("Failed to write Prometheus file", "path", w.Path, LogError, err)
+				continue
+			}
+			last = b
+			w.Srv.logDebug("Wrote Prometheus file", "path", w.Path, LogServiceType, w.Config.ServiceType, "targets", len(l))
+		}
+	}
+}
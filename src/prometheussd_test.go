@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+func TestNewPrometheusTargets(t *testing.T) {
+	services := []api.ServiceItem{
+		{
+			Name:     "web/b/8080",
+			Service:  "_metrics._tcp",
+			Domain:   "local.",
+			HostName: "b.local.",
+			Port:     8080,
+			AddrIPv4: []net.IP{net.ParseIP("10.0.0.2")},
+			Text:     []string{"path=/stats", "Env-Name=prod", "debug", "=ignored", "health=healthy"},
+		},
+		{
+			Name:     "web/a/9100",
+			Service:  "_metrics._tcp",
+			Domain:   "local.",
+			HostName: "a.local.",
+			Port:     9100,
+			AddrIPv6: []net.IP{net.ParseIP("fe80::1")},
+		},
+		{Name: "no address", Service: "_metrics._tcp", Domain: "local.", Port: 9100},
+	}
+	want := []prometheusTargetGroup{
+		{
+			Targets: []string{"[fe80::1]:9100"},
+			Labels: map[string]string{
+				"__meta_zeroconf_instance":     "web/a/9100",
+				"__meta_zeroconf_service_type": "_metrics._tcp",
+				"__meta_zeroconf_domain":       "local.",
+				"__meta_zeroconf_hostname":     "a.local",
+			},
+		},
+		{
+			Targets: []string{"10.0.0.2:8080"},
+			Labels: map[string]string{
+				"__meta_zeroconf_path":         "/stats",
+				"__meta_zeroconf_env_name":     "prod",
+				"__meta_zeroconf_debug":        "true",
+				"__meta_zeroconf_health":       "healthy",
+				"__meta_zeroconf_instance":     "web/b/8080",
+				"__meta_zeroconf_service_type": "_metrics._tcp",
+				"__meta_zeroconf_domain":       "local.",
+				"__meta_zeroconf_hostname":     "b.local",
+			},
+		},
+	}
+	got := NewPrometheusTargets(services)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewPrometheusTargets() = %+v, want %+v", got, want)
+	}
+
+	// An empty search is an empty list, not null, so that Prometheus accepts it
+	b, _ := json.Marshal(NewPrometheusTargets(nil))
+	if string(b) != "[]" {
+		t.Errorf("NewPrometheusTargets(nil) = %s, want []", b)
+	}
+}
+
+func TestPrometheusTargetsKeepInstanceLabels(t *testing.T) {
+	// TXT entries cannot replace the labels set from the instance
+	s := api.ServiceItem{Name: "web", Service: "_metrics._tcp", Domain: "local.", Port: 80,
+		AddrIPv4: []net.IP{net.ParseIP("10.0.0.1")}, Text: []string{"instance=other", "service.type=other"}}
+	l := NewPrometheusTargets([]api.ServiceItem{s})[0].Labels
+	if l["__meta_zeroconf_instance"] != "web" || l["__meta_zeroconf_service_type"] != "_metrics._tcp" {
+		t.Errorf("labels = %v, want the instance name and service type", l)
+	}
+}
+
+func TestPrometheusLabelName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"path", "path"},
+		{"Env-Name", "env_name"},
+		{"a.b c", "a_b_c"},
+		{"k8s_ns", "k8s_ns"},
+		{"Ünï", "_n_"},
+	}
+	for _, tt := range tests {
+		if got := prometheusLabelName(tt.key); got != tt.want {
+			t.Errorf("prometheusLabelName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestIsServiceType(t *testing.T) {
+	tests := []struct {
+		serviceType string
+		want        bool
+	}{
+		{"_metrics._tcp", true},
+		{"_dns._udp", true},
+		{"_metrics._tcp.", true},
+		{"metrics._tcp", false},
+		{"_metrics", false},
+		{"_._tcp", false},
+		{"_metrics._sctp", false},
+		{"_a._b._tcp", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isServiceType(tt.serviceType); got != tt.want {
+			t.Errorf("isServiceType(%q) = %v, want %v", tt.serviceType, got, tt.want)
+		}
+	}
+}
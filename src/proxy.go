@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// Load balancing methods used to choose the instance a proxied request is sent to
+const (
+	BalanceRoundRobin       = "round-robin"       // Each instance is used in turn
+	BalanceRandom           = "random"            // An instance is chosen at random
+	BalanceLeastConnections = "least-connections" // The instance with the fewest requests in progress is used
+)
+
+const (
+	proxyDialTimeout = 5 * time.Second  // Maximum time to wait for a connection to an instance
+	proxyRetryBody   = 1 << 20          // Largest request body that is kept so that the request can be retried
+	proxyIdleTime    = 10 * time.Minute // Time after which the instances of an unused service type are forgotten
+)
+
+// ProxyConfig defines how requests to /proxy/{serviceType}/ are forwarded to the instances of a service type
+type ProxyConfig struct {
+	Enabled      bool     `json:"enabled"`                // Indicates whether requests are proxied.  Defaults to false
+	Balance      string   `json:"balance,omitempty"`      // Load balancing method, either "round-robin", "random" or "least-connections".  Defaults to "round-robin"
+	Retries      int      `json:"retries,omitempty"`      // Number of other instances tried if an instance cannot be reached.  Defaults to 2, or none if negative
+	CacheTime    int      `json:"cacheTime,omitempty"`    // Duration in secs the instances found are used before searching again.  Defaults to 10
+	ServiceTypes []string `json:"serviceTypes,omitempty"` // Service types that may be proxied.  If empty, any service type may be proxied
+}
+
+// Validate checks the proxy configuration values for errors
+func (c *ProxyConfig) Validate() error {
+	switch c.Balance {
+	case "", BalanceRoundRobin, BalanceRandom, BalanceLeastConnections:
+	default:
+		return fmt.Errorf("invalid balance '%s'", c.Balance)
+	}
+	if c.CacheTime < 0 {
+		return errors.New("cacheTime must not be negative")
+	}
+	return nil
+}
+
+// SetDefaults checks the values and sets the defaults
+func (c *ProxyConfig) SetDefaults() {
+	if c.Balance == "" {
+		c.Balance = BalanceRoundRobin
+	}
+	if c.Retries == 0 {
+		c.Retries = 2
+	} else if c.Retries < 0 {
+		c.Retries = 0
+	}
+	if c.CacheTime <= 0 {
+		c.CacheTime = 10
+	}
+}
+
+// Allows returns whether requests to the service type may be proxied
+func (c *ProxyConfig) Allows(serviceType string) bool {
+	if len(c.ServiceTypes) == 0 {
+		return true
+	}
+	for _, t := range c.ServiceTypes {
+		if t == serviceType {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceProxy keeps the healthy instances of each proxied service type and sends requests
+// to them, trying other instances if an instance cannot be reached
+type ServiceProxy struct {
+	Srv       *Server               // Web Server
+	pools     map[string]*proxyPool // Instances found, by service type
+	lock      sync.Mutex            // Mutex lock for pools
+	transport http.RoundTripper     // Transport used to send requests to the instances
+}
+
+// NewServiceProxy creates a proxy that searches for instances using the server
+func NewServiceProxy(s *Server) *ServiceProxy {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: proxyDialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	return &ServiceProxy{
+		Srv:       s,
+		pools:     map[string]*proxyPool{},
+		transport: t,
+	}
+}
+
+// proxyPool holds the instances found for a service type
+type proxyPool struct {
+	backends []*proxyBackend // Instances found by the last search, sorted by name
+	err      error           // Error from the last search
+	found    time.Time       // Date and time of the last successful search
+	used     time.Time       // Date and time the pool was last used
+	busy     bool            // Indicates whether a search is in progress
+	ready    chan struct{}   // Closed once the first search has completed
+	next     uint32          // Position of the next instance to use for round-robin balancing
+}
+
+// proxyBackend is an instance of a service type that requests can be sent to
+type proxyBackend struct {
+	Name   string    // Name of the service instance
+	Scheme string    // URL scheme used to reach the instance, either "http" or "https"
+	Addr   string    // Address of the instance in host:port format
+	active int32     // Number of requests in progress
+	down   time.Time // Date and time the instance last could not be reached
+}
+
+// Targets returns the instances a request to the service type should be tried against, in order.
+// The first search for a service type waits for the results, after which the instances found are
+// used while they are searched for again in the background.
+func (p *ServiceProxy) Targets(ctx context.Context, serviceType string, c ProxyConfig) ([]*proxyBackend, error) {
+	now := time.Now()
+	p.lock.Lock()
+	for t, pl := range p.pools {
+		if !pl.busy && now.Sub(pl.used) > proxyIdleTime {
+			delete(p.pools, t)
+		}
+	}
+	pl, ok := p.pools[serviceType]
+	if !ok {
+		pl = &proxyPool{ready: make(chan struct{}), next: uint32(rand.Intn(1 << 16))}
+		p.pools[serviceType] = pl
+	}
+	pl.used = now
+	if !pl.busy && now.Sub(pl.found) > time.Duration(c.CacheTime)*time.Second {
+		pl.busy = true
+		go p.search(serviceType, pl)
+	}
+	p.lock.Unlock()
+
+	select {
+	case <-pl.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(pl.backends) == 0 {
+		return nil, pl.err
+	}
+	b := p.order(pl, c.Balance, time.Duration(c.CacheTime)*time.Second)
+	if len(b) > c.Retries+1 {
+		b = b[:c.Retries+1]
+	}
+	return b, nil
+}
+
+// search searches for the healthy instances of the service type and updates the pool
+func (p *ServiceProxy) search(serviceType string, pl *proxyPool) {
+	resp, err := p.Srv.GetServiceList(api.GetRequest{ServiceType: serviceType, Domain: "local", OnlyHealthy: true})
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pl.busy = false
+	pl.err = err
+	if err == nil {
+		known := map[string]*proxyBackend{}
+		for _, b := range pl.backends {
+			known[b.Name+" "+b.Addr] = b
+		}
+		backends := []*proxyBackend{}
+		for _, s := range resp.Services {
+			b := newProxyBackend(s)
+			if b == nil {
+				continue
+			}
+			// Keep the requests in progress of instances that were already known
+			if k, ok := known[b.Name+" "+b.Addr]; ok && k.Scheme == b.Scheme {
+				b = k
+			}
+			backends = append(backends, b)
+		}
+		sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+		pl.backends = backends
+		pl.found = time.Now()
+	}
+	select {
+	case <-pl.ready:
+	default:
+		close(pl.ready)
+	}
+}
+
+// order returns the instances of the pool in the order they should be tried using the balancing method.
+// Instances that recently could not be reached are tried last.
+func (p *ServiceProxy) order(pl *proxyPool, balance string, downTime time.Duration) []*proxyBackend {
+	n := len(pl.backends)
+	b := make([]*proxyBackend, n)
+	switch balance {
+	case BalanceRandom:
+		for i, j := range rand.Perm(n) {
+			b[i] = pl.backends[j]
+		}
+	default:
+		start := int(pl.next % uint32(n))
+		pl.next++
+		for i := range b {
+			b[i] = pl.backends[(start+i)%n]
+		}
+		if balance == BalanceLeastConnections {
+			sort.SliceStable(b, func(i, j int) bool {
+				return atomic.LoadInt32(&b[i].active) < atomic.LoadInt32(&b[j].active)
+			})
+		}
+	}
+	now := time.Now()
+	sort.SliceStable(b, func(i, j int) bool {
+		return now.Sub(b[i].down) > downTime && now.Sub(b[j].down) <= downTime
+	})
+	return b
+}
+
+// markDown records that the instance could not be reached
+func (p *ServiceProxy) markDown(b *proxyBackend) {
+	p.lock.Lock()
+	b.down = time.Now()
+	p.lock.Unlock()
+}
+
+// newProxyBackend returns the instance that requests to the service are sent to, or nil if it has no address.
+// Instances that publish a "scheme=https" text entry are reached using https.
+func newProxyBackend(s api.ServiceItem) *proxyBackend {
+	host := s.HostName
+	if len(s.AddrIPv4) != 0 {
+		host = s.AddrIPv4[0].String()
+	} else if len(s.AddrIPv6) != 0 {
+		host = s.AddrIPv6[0].String()
+	}
+	if host == "" || s.Port <= 0 {
+		return nil
+	}
+	scheme := "http"
+	if v, ok := s.TextValue("scheme"); ok && v == "https" {
+		scheme = "https"
+	}
+	return &proxyBackend{Name: s.Name, Scheme: scheme, Addr: net.JoinHostPort(host, strconv.Itoa(s.Port))}
+}
+
+// proxyTargetsKey is the context key used to store the proxy attempt of a request
+type proxyTargetsKey struct{}
+
+// proxyAttempt holds the instances a proxied request is tried against
+type proxyAttempt struct {
+	ServiceType string          // Service type of the instances
+	Backends    []*proxyBackend // Instances to try, in order
+	Body        []byte          // Request body, or nil if the body cannot be sent again
+	Retries     int             // Number of other instances the request was sent to
+}
+
+// RoundTrip sends the request to the instances of the proxy attempt held in its context, trying the next
+// instance if an instance cannot be reached.  Requests that are not idempotent are only sent to another
+// instance if the connection could not be made, as the first instance may already have acted on them.
+func (p *ServiceProxy) RoundTrip(req *http.Request) (*http.Response, error) {
+	a, ok := req.Context().Value(proxyTargetsKey{}).(*proxyAttempt)
+	if !ok || len(a.Backends) == 0 {
+		return nil, errors.New("no instances to send the request to")
+	}
+	var err error
+	for i, b := range a.Backends {
+		out := req.Clone(req.Context())
+		out.URL.Scheme = b.Scheme
+		out.URL.Host = b.Addr
+		if i != 0 && out.Body != nil {
+			out.Body = io.NopCloser(bytes.NewReader(a.Body))
+		}
+
+		atomic.AddInt32(&b.active, 1)
+		var resp *http.Response
+		resp, err = p.transport.RoundTrip(out)
+		if err == nil {
+			resp.Body = &proxyBody{ReadCloser: resp.Body, release: func() { atomic.AddInt32(&b.active, -1) }}
+			return resp, nil
+		}
+		atomic.AddInt32(&b.active, -1)
+		p.markDown(b)
+		componentLog("ServiceProxy").Warn("Failed to reach service instance", LogServiceType, a.ServiceType,
+			"instance", b.Name, "address", b.Addr, LogRequestID, RequestIDFromContext(req.Context()), LogError, err)
+
+		if req.Context().Err() != nil || (req.Body != nil && req.Body != http.NoBody && a.Body == nil) || !(isIdempotent(req.Method) || isDialError(err)) {
+			break
+		}
+		if i+1 < len(a.Backends) {
+			a.Retries++
+		}
+	}
+	return nil, err
+}
+
+// isIdempotent returns whether requests with the method can safely be sent more than once
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// isDialError returns whether the error occurred while connecting, before the request was sent
+func isDialError(err error) bool {
+	var oe *net.OpError
+	return errors.As(err, &oe) && oe.Op == "dial"
+}
+
+// proxyBody is the body of a response from an instance, which marks the request as no longer in progress once closed
+type proxyBody struct {
+	io.ReadCloser
+	release func()    // Marks the request as no longer in progress
+	once    sync.Once // Ensures the request is only released once
+}
+
+// Close closes the body and marks the request as no longer in progress
+func (b *proxyBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// Write writes to the body of a switched protocol (e.g. WebSocket) response
+func (b *proxyBody) Write(p []byte) (int, error) {
+	if w, ok := b.ReadCloser.(io.Writer); ok {
+		return w.Write(p)
+	}
+	return 0, errors.New("response body is not writable")
+}
+
+// withProxyAttempt returns a context holding the proxy attempt of a request
+func withProxyAttempt(ctx context.Context, a *proxyAttempt) context.Context {
+	return context.WithValue(ctx, proxyTargetsKey{}, a)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// fakeTransport answers proxied requests, failing those sent to the addresses in errs
+type fakeTransport struct {
+	errs map[string]error // Error returned for requests to each address
+	sent []string         // Addresses the requests were sent to, in order
+	body []string         // Bodies of the requests, in order
+}
+
+// RoundTrip records the request and returns the error for its address, or an empty response
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.sent = append(t.sent, req.URL.Host)
+	b := ""
+	if req.Body != nil {
+		v, _ := io.ReadAll(req.Body)
+		b = string(v)
+	}
+	t.body = append(t.body, b)
+	if err := t.errs[req.URL.Host]; err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func proxyBackends(names ...string) []*proxyBackend {
+	l := []*proxyBackend{}
+	for _, n := range names {
+		l = append(l, &proxyBackend{Name: n, Scheme: "http", Addr: n + ":80"})
+	}
+	return l
+}
+
+func backendNames(l []*proxyBackend) string {
+	n := []string{}
+	for _, b := range l {
+		n = append(n, b.Name)
+	}
+	return strings.Join(n, ",")
+}
+
+func TestProxyOrderRoundRobin(t *testing.T) {
+	p := &ServiceProxy{}
+	pl := &proxyPool{backends: proxyBackends("a", "b", "c")}
+	want := []string{"a,b,c", "b,c,a", "c,a,b", "a,b,c"}
+	for i, w := range want {
+		if got := backendNames(p.order(pl, BalanceRoundRobin, time.Minute)); got != w {
+			t.Errorf("request %d: order = %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestProxyOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance string
+		active  []int32
+		down    []bool
+		want    string
+	}{
+		{"least connections", BalanceLeastConnections, []int32{2, 0, 1}, nil, "b,c,a"},
+		{"least connections keeps round-robin order on ties", BalanceLeastConnections, []int32{1, 0, 0}, nil, "b,c,a"},
+		{"down instance tried last", BalanceRoundRobin, nil, []bool{true, false, false}, "b,c,a"},
+		{"down instances keep their order", BalanceRoundRobin, nil, []bool{true, true, false}, "c,a,b"},
+		{"least connections down instance tried last", BalanceLeastConnections, []int32{0, 1, 2}, []bool{true, false, false}, "b,c,a"},
+	}
+	for _, tt := range tests {
+		p := &ServiceProxy{}
+		pl := &proxyPool{backends: proxyBackends("a", "b", "c")}
+		for i, b := range pl.backends {
+			if tt.active != nil {
+				b.active = tt.active[i]
+			}
+			if tt.down != nil && tt.down[i] {
+				b.down = time.Now()
+			}
+		}
+		if got := backendNames(p.order(pl, tt.balance, time.Minute)); got != tt.want {
+			t.Errorf("%s: order = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProxyOrderRandom(t *testing.T) {
+	p := &ServiceProxy{}
+	pl := &proxyPool{backends: proxyBackends("a", "b", "c")}
+	pl.backends[0].down = time.Now()
+	for i := 0; i < 20; i++ {
+		l := p.order(pl, BalanceRandom, time.Minute)
+		if len(l) != 3 || l[2].Name != "a" {
+			t.Fatalf("order = %s, want every instance with a last", backendNames(l))
+		}
+	}
+}
+
+func TestProxyRoundTrip(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		keep    bool
+		errs    map[string]error
+		sent    string
+		retries int
+		fail    bool
+	}{
+		{"first instance answers", "GET", "", false, nil, "a:80", 0, false},
+		{"GET retried after dial error", "GET", "", false, map[string]error{"a:80": dialErr}, "a:80,b:80", 1, false},
+		{"GET retried after read error", "GET", "", false, map[string]error{"a:80": readErr}, "a:80,b:80", 1, false},
+		{"POST retried after dial error", "POST", "x", true, map[string]error{"a:80": dialErr}, "a:80,b:80", 1, false},
+		{"POST not retried after read error", "POST", "x", true, map[string]error{"a:80": readErr}, "a:80", 0, true},
+		{"PUT with unkept body not retried", "PUT", "x", false, map[string]error{"a:80": dialErr}, "a:80", 0, true},
+		{"every instance down", "GET", "", false, map[string]error{"a:80": dialErr, "b:80": dialErr, "c:80": dialErr}, "a:80,b:80,c:80", 2, true},
+	}
+	for _, tt := range tests {
+		ft := &fakeTransport{errs: tt.errs}
+		p := &ServiceProxy{transport: ft}
+		a := &proxyAttempt{ServiceType: "_web._tcp", Backends: proxyBackends("a", "b", "c")}
+		if tt.keep {
+			a.Body = []byte(tt.body)
+		}
+		var body io.Reader
+		if tt.body != "" {
+			body = strings.NewReader(tt.body)
+		}
+		req, _ := http.NewRequest(tt.method, "http://zcservice/proxy/_web._tcp/", body)
+		req = req.WithContext(withProxyAttempt(req.Context(), a))
+
+		resp, err := p.RoundTrip(req)
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: error = %v, want failure %v", tt.name, err, tt.fail)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if got := strings.Join(ft.sent, ","); got != tt.sent {
+			t.Errorf("%s: sent to %s, want %s", tt.name, got, tt.sent)
+		}
+		if a.Retries != tt.retries {
+			t.Errorf("%s: retries = %d, want %d", tt.name, a.Retries, tt.retries)
+		}
+		for i, b := range ft.body {
+			if b != tt.body {
+				t.Errorf("%s: body of request %d = %q, want %q", tt.name, i, b, tt.body)
+			}
+		}
+		for _, b := range a.Backends {
+			if b.active != 0 {
+				t.Errorf("%s: instance %s has %d requests in progress, want 0", tt.name, b.Name, b.active)
+			}
+		}
+	}
+}
+
+func TestProxyRoundTripMarksDown(t *testing.T) {
+	ft := &fakeTransport{errs: map[string]error{"a:80": &net.OpError{Op: "dial", Err: errors.New("refused")}}}
+	p := &ServiceProxy{transport: ft}
+	a := &proxyAttempt{Backends: proxyBackends("a", "b")}
+	req, _ := http.NewRequest("GET", "http://zcservice/", nil)
+	resp, err := p.RoundTrip(req.WithContext(withProxyAttempt(req.Context(), a)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if a.Backends[0].down.IsZero() || !a.Backends[1].down.IsZero() {
+		t.Errorf("down = %v, %v, want only the first instance down", a.Backends[0].down, a.Backends[1].down)
+	}
+}
+
+func TestNewProxyBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		service api.ServiceItem
+		want    *proxyBackend
+	}{
+		{"IPv4 preferred", api.ServiceItem{Name: "a", HostName: "a.local.", Port: 80, AddrIPv4: []net.IP{net.ParseIP("10.0.0.1")}, AddrIPv6: []net.IP{net.ParseIP("fe80::1")}},
+			&proxyBackend{Name: "a", Scheme: "http", Addr: "10.0.0.1:80"}},
+		{"IPv6 bracketed", api.ServiceItem{Name: "a", Port: 80, AddrIPv6: []net.IP{net.ParseIP("fe80::1")}},
+			&proxyBackend{Name: "a", Scheme: "http", Addr: "[fe80::1]:80"}},
+		{"host name without addresses", api.ServiceItem{Name: "a", HostName: "a.local.", Port: 8080},
+			&proxyBackend{Name: "a", Scheme: "http", Addr: "a.local.:8080"}},
+		{"https scheme", api.ServiceItem{Name: "a", Port: 443, AddrIPv4: []net.IP{net.ParseIP("10.0.0.1")}, Text: []string{"scheme=https"}},
+			&proxyBackend{Name: "a", Scheme: "https", Addr: "10.0.0.1:443"}},
+		{"no address", api.ServiceItem{Name: "a", Port: 80}, nil},
+		{"no port", api.ServiceItem{Name: "a", AddrIPv4: []net.IP{net.ParseIP("10.0.0.1")}}, nil},
+	}
+	for _, tt := range tests {
+		got := newProxyBackend(tt.service)
+		if (got == nil) != (tt.want == nil) || (got != nil && (got.Name != tt.want.Name || got.Scheme != tt.want.Scheme || got.Addr != tt.want.Addr)) {
+			t.Errorf("%s: newProxyBackend = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProxyConfigDefaults(t *testing.T) {
+	tests := []struct {
+		retries int
+		want    int
+	}{
+		{0, 2},
+		{-1, 0},
+		{5, 5},
+	}
+	for _, tt := range tests {
+		c := ProxyConfig{Retries: tt.retries}
+		c.SetDefaults()
+		if c.Retries != tt.want {
+			t.Errorf("Retries %d: SetDefaults() = %d, want %d", tt.retries, c.Retries, tt.want)
+		}
+	}
+}
+
+func TestProxyUpgrade(t *testing.T) {
+	// The instance switches to a line echo protocol when asked to upgrade
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" || r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "Upgrade required", 426)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		brw.Flush()
+		line, _ := brw.ReadString('\n')
+		brw.WriteString("echo: " + line)
+		brw.Flush()
+	}))
+	defer backend.Close()
+
+	s := &Server{}
+	s.config.Store(&Config{Proxy: ProxyConfig{Enabled: true}})
+	router := mux.NewRouter()
+	c := new(ProxyController)
+	c.AddController(router, s)
+	ready := make(chan struct{})
+	close(ready)
+	c.proxy.pools["_echo._tcp"] = &proxyPool{
+		backends: []*proxyBackend{{Name: "echo", Scheme: "http", Addr: backend.Listener.Addr().String()}},
+		found:    time.Now(),
+		used:     time.Now(),
+		ready:    ready,
+	}
+	front := httptest.NewServer(router)
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET /proxy/_echo._tcp/ws HTTP/1.1\r\nHost: zcservice\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 101 {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d %s, want 101", resp.StatusCode, b)
+	}
+	io.WriteString(conn, "hello\n")
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "echo: hello\n" {
+		t.Errorf("upgraded connection returned %q, want %q", line, "echo: hello\n")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ProxyController handles the web methods that forward requests to the instances of a service type
+type ProxyController struct {
+	Srv   *Server                // Web Server
+	proxy *ServiceProxy          // Keeps the instances of each service type and sends requests to them
+	rp    *httputil.ReverseProxy // Forwards requests and copies back the responses
+}
+
+// AddController adds the controller routes to the router
+func (c *ProxyController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	c.proxy = NewServiceProxy(s)
+	c.rp = &httputil.ReverseProxy{
+		Rewrite:       c.rewrite,
+		Transport:     c.proxy,
+		FlushInterval: -1,
+		ErrorHandler:  c.handleError,
+	}
+	router.PathPrefix("/proxy/{serviceType}/").
+		Handler(Logger(c, http.HandlerFunc(c.handleProxy)))
+	router.Path("/proxy/{serviceType}").
+		Handler(Logger(c, http.HandlerFunc(c.handleProxy)))
+}
+
+// handleProxy handles the /proxy/{serviceType}/ web method call
+func (c *ProxyController) handleProxy(w http.ResponseWriter, r *http.Request) {
+	cfg := c.Srv.Config().Proxy
+	if !cfg.Enabled {
+		http.Error(w, "Proxy is not enabled.", 404)
+		return
+	}
+	cfg.SetDefaults()
+	st := mux.Vars(r)["serviceType"]
+	if !cfg.Allows(st) {
+		http.Error(w, "Service type may not be proxied.", 403)
+		return
+	}
+	backends, err := c.proxy.Targets(r.Context(), st, cfg)
+	if err != nil {
+		http.Error(w, "Failed to search for the service. "+err.Error(), 502)
+		return
+	}
+	if len(backends) == 0 {
+		http.Error(w, "No healthy instances of the service were found.", 503)
+		return
+	}
+
+	// Keep small request bodies so that the request can be sent to another instance
+	a := &proxyAttempt{ServiceType: st, Backends: backends}
+	if r.Body != nil && r.Body != http.NoBody && len(backends) > 1 {
+		b, err := io.ReadAll(io.LimitReader(r.Body, proxyRetryBody+1))
+		if err != nil {
+			http.Error(w, "Failed to read the request body.", 400)
+			return
+		}
+		if len(b) <= proxyRetryBody {
+			a.Body = b
+			r.Body = io.NopCloser(bytes.NewReader(b))
+		} else {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		}
+	}
+
+	sw := &statusWriter{ResponseWriter: w, status: 200}
+	c.rp.ServeHTTP(sw, r.WithContext(withProxyAttempt(r.Context(), a)))
+	proxyRequestsTotal.WithLabelValues(st, strconv.Itoa(sw.status)).Inc()
+	if a.Retries != 0 {
+		proxyRetriesTotal.WithLabelValues(st).Add(float64(a.Retries))
+	}
+}
+
+// rewrite sets the path of the request sent to the instance to the part of the path after the service type.
+// The instance address is set by the proxy transport.
+func (c *ProxyController) rewrite(pr *httputil.ProxyRequest) {
+	ep := pr.In.URL.EscapedPath()
+	prefix := ep
+	rest := "/"
+	// The escaped path is /proxy/{serviceType}/...
+	if i := strings.Index(ep[len("/proxy/"):], "/"); i >= 0 {
+		prefix = ep[:len("/proxy/")+i]
+		rest = ep[len(prefix):]
+	}
+	pr.Out.URL.Scheme = "http"
+	pr.Out.URL.Host = "instance"
+	pr.Out.URL.Path, _ = url.PathUnescape(rest)
+	pr.Out.URL.RawPath = rest
+	pr.Out.Host = ""
+	pr.SetXForwarded()
+	pr.Out.Header.Set("X-Forwarded-Prefix", prefix)
+	pr.Out.Header.Set("X-Request-ID", RequestIDFromContext(pr.In.Context()))
+}
+
+// handleError handles requests that could not be sent to any instance of the service
+func (c *ProxyController) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		// The client has gone away
+		w.WriteHeader(499)
+		return
+	}
+	http.Error(w, "No instance of the service could be reached.", 502)
+}
+
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *ProxyController) LogInfo(msg string, args ...interface{}) {
+	componentLog("ProxyController").Info(msg, args...)
+}
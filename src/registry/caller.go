@@ -0,0 +1,12 @@
+package registry
+
+import (
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// Caller identifies the client that requested a change
+type Caller struct {
+	Addr      string        // Remote address of the client
+	Owner     *api.PeerCred // Credentials of the client process, if it connected over a Unix socket
+	RequestID string        // ID of the http request
+}
@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"sync"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// EventHub distributes registration events to subscribers
+type EventHub struct {
+	subs map[chan api.Event]struct{} // Subscriber channels
+	lock sync.Mutex                  // Mutex lock for adding and removing subscribers
+}
+
+// NewEventHub creates a new event hub
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subs: make(map[chan api.Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives all published events
+func (h *EventHub) Subscribe() chan api.Event {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	c := make(chan api.Event, 32)
+	h.subs[c] = struct{}{}
+	return c
+}
+
+// Unsubscribe stops sending events to the specified channel and closes it
+func (h *EventHub) Unsubscribe(c chan api.Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.subs[c]; ok {
+		delete(h.subs, c)
+		close(c)
+	}
+}
+
+// Publish sends the event to all subscribers.
+// Events are dropped for subscribers that are not keeping up.
+func (h *EventHub) Publish(e api.Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for c := range h.subs {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
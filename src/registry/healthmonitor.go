@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// HealthMonitor periodically runs a health check and reports changes in the health state
+type HealthMonitor struct {
+	Check    api.HealthCheck    // Health check definition
+	PortNo   int                // Port number of the service being checked
+	OnChange func(state string) // Called when the health state changes
+	state    string             // Current health state
+	passes   int                // Number of consecutive passes
+	failures int                // Number of consecutive failures
+	stop     chan struct{}      // Monitor stop signal
+}
+
+// NewHealthMonitor creates a new monitor for the specified health check
+func NewHealthMonitor(c api.HealthCheck, portNo int, onChange func(state string)) *HealthMonitor {
+	c.SetDefaults()
+	return &HealthMonitor{
+		Check:    c,
+		PortNo:   portNo,
+		OnChange: onChange,
+		state:    api.HealthUnknown,
+	}
+}
+
+// Start starts running the health check
+func (m *HealthMonitor) Start() {
+	m.stop = make(chan struct{})
+	go m.run()
+}
+
+// Stop stops running the health check
+func (m *HealthMonitor) Stop() {
+	if m.stop == nil {
+		return
+	}
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+}
+
+func (m *HealthMonitor) run() {
+	t := time.NewTicker(time.Duration(m.Check.Interval) * time.Second)
+	defer t.Stop()
+	for {
+		m.update(m.runCheck())
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// update applies a check result to the thresholds and reports any change in state
+func (m *HealthMonitor) update(err error) {
+	ns := m.state
+	if err == nil {
+		m.passes++
+		m.failures = 0
+		if m.state == api.HealthUnknown || m.passes >= m.Check.HealthyThreshold {
+			ns = api.HealthHealthy
+		}
+	} else {
+		m.failures++
+		m.passes = 0
+		if m.state == api.HealthUnknown || m.failures >= m.Check.UnhealthyThreshold {
+			ns = api.HealthUnhealthy
+		}
+	}
+	if ns != m.state {
+		m.state = ns
+		if m.OnChange != nil {
+			m.OnChange(ns)
+		}
+	}
+}
+
+// runCheck runs the health check once.  A nil error means the check passed.
+func (m *HealthMonitor) runCheck() error {
+	to := time.Duration(m.Check.Timeout) * time.Second
+	addr := net.JoinHostPort(m.Check.Host, strconv.Itoa(m.PortNo))
+	switch m.Check.Type {
+	case "tcp":
+		c, err := net.DialTimeout("tcp", addr, to)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+
+	case "http":
+		cl := http.Client{Timeout: to}
+		resp, err := cl.Get("http://" + addr + m.Check.Path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != m.Check.ExpectedStatus {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+
+	case "script":
+		ctx, cancel := context.WithTimeout(context.Background(), to)
+		defer cancel()
+		return exec.CommandContext(ctx, m.Check.Script, m.Check.Args...).Run()
+	}
+	return fmt.Errorf("invalid health check type '%s'", m.Check.Type)
+}
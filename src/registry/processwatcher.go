@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"time"
+)
+
+// processPollInterval is the interval used when polling for a process to exit
+const processPollInterval = 500 * time.Millisecond
+
+// ProcessWatcher watches a process and calls OnExit as soon as the process disappears
+type ProcessWatcher struct {
+	PID    int           // Process ID to watch
+	OnExit func()        // Called when the process has exited
+	stop   chan struct{} // Watcher stop signal
+}
+
+// NewProcessWatcher creates a new watcher for the specified process
+func NewProcessWatcher(pid int, onExit func()) *ProcessWatcher {
+	return &ProcessWatcher{
+		PID:    pid,
+		OnExit: onExit,
+	}
+}
+
+// Start starts watching the process
+func (w *ProcessWatcher) Start() {
+	w.stop = make(chan struct{})
+	go w.run()
+}
+
+// Stop stops watching the process without calling OnExit
+func (w *ProcessWatcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+}
+
+func (w *ProcessWatcher) run() {
+	if w.waitExit() && w.OnExit != nil {
+		w.OnExit()
+	}
+}
+
+// pollExit polls the process until it no longer exists.
+// Returns true if the process exited, false if the watcher was stopped.
+func (w *ProcessWatcher) pollExit() bool {
+	t := time.NewTicker(processPollInterval)
+	defer t.Stop()
+	for {
+		if !ProcessExists(w.PID) {
+			return true
+		}
+		select {
+		case <-w.stop:
+			return false
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package registry
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitExit waits for the process to exit using a pidfd, falling back to polling /proc
+// if pidfds are not supported by the kernel.
+// Returns true if the process exited, false if the watcher was stopped.
+func (w *ProcessWatcher) waitExit() bool {
+	fd, err := unix.PidfdOpen(w.PID, 0)
+	if err != nil {
+		return w.pollExit()
+	}
+	defer unix.Close(fd)
+
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		n, err := unix.Poll(fds, int(processPollInterval.Milliseconds()))
+		if err != nil && err != unix.EINTR {
+			return w.pollExit()
+		}
+		if n > 0 {
+			return true
+		}
+		select {
+		case <-w.stop:
+			return false
+		default:
+		}
+	}
+}
+
+// ProcessExists returns whether the process is still running
+func ProcessExists(pid int) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	return err == nil
+}
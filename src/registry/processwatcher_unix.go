@@ -0,0 +1,19 @@
+//go:build !linux && !windows
+
+package registry
+
+import (
+	"syscall"
+)
+
+// waitExit polls for the process to exit.
+// Returns true if the process exited, false if the watcher was stopped.
+func (w *ProcessWatcher) waitExit() bool {
+	return w.pollExit()
+}
+
+// ProcessExists returns whether the process is still running
+func ProcessExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package registry
+
+import (
+	"syscall"
+)
+
+const (
+	processSynchronize      = 0x00100000
+	processQueryLimitedInfo = 0x00001000
+	processStillActive      = 259
+	processWaitObject0      = 0x00000000
+)
+
+// waitExit waits on the process handle for the process to exit.
+// Returns true if the process exited, false if the watcher was stopped.
+func (w *ProcessWatcher) waitExit() bool {
+	h, err := syscall.OpenProcess(processSynchronize, false, uint32(w.PID))
+	if err != nil {
+		return w.pollExit()
+	}
+	defer syscall.CloseHandle(h)
+
+	for {
+		e, err := syscall.WaitForSingleObject(h, uint32(processPollInterval.Milliseconds()))
+		if err != nil {
+			return w.pollExit()
+		}
+		if e == processWaitObject0 {
+			return true
+		}
+		select {
+		case <-w.stop:
+			return false
+		default:
+		}
+	}
+}
+
+// ProcessExists returns whether the process is still running
+func ProcessExists(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInfo, false, uint32(pid))
+	if err != nil {
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+	var c uint32
+	if err := syscall.GetExitCodeProcess(h, &c); err != nil {
+		return true
+	}
+	return c == processStillActive
+}
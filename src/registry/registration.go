@@ -0,0 +1,390 @@
+package registry
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/grandcat/zeroconf"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// Registration defines a Zeroconf service registration
+type Registration struct {
+	ID          string           // ID of the service
+	Name        string           // Service Instance Name
+	PortNo      int              // Port number service is available on
+	ServiceType string           // Service Type
+	Subtypes    []string         // Service Subtypes
+	Domain      string           // Domain name
+	Text        []string         // Associated Text
+	LastContact time.Time        // Date and time of last contact
+	Owner       *api.PeerCred    // Credentials of the registering process, if known
+	PID         int              // Process ID being watched, or 0 if none
+	HealthCheck *api.HealthCheck // Health check that gates the announcement, if any
+	Host        string           // Host name of the service, if it runs on another machine
+	IPs         []string         // IP addresses of Host
+	Source      string           // Source of the registration
+	reg         *Registry        // Registry the service is registered with
+	shutdown    chan bool        // Registration shutdown signal
+	isRunning   bool             // Indicate whether currently running
+	watcher     *ProcessWatcher  // Watches the registering process
+	monitor     *HealthMonitor   // Runs the health check
+	healthCh    chan struct{}    // Health state change signal
+	health      string           // Current health state
+	zsrv        *zeroconf.Server // Zeroconf server announcing the service
+	lastError   string           // Error from the last failed announcement
+	lock        sync.Mutex       // Mutex lock for the health state and zeroconf server
+}
+
+// newRegistration creates a new registration from the specified registration request,
+// made by the caller from the specified source
+func (g *Registry) newRegistration(r *api.RegisterRequest, source string, c Caller) *Registration {
+	r.SetDefaults()
+	if source == "" {
+		source = api.SourceAPI
+	}
+	if r.ID == "" {
+		if uuid, err := uuid.NewV4(); err == nil {
+			r.ID = strings.Replace(uuid.String(), "-", "", -1)
+		}
+	}
+	if r.ServiceType == "" {
+		r.ServiceType = g.DefaultServiceType
+	}
+	if r.Domain == "" {
+		r.Domain = "local."
+	}
+	hostName := g.HostName
+	hc := r.HealthCheck
+	if r.Host != "" {
+		// The service runs on another machine, so name it after that machine and check its health there
+		hostName = strings.Split(r.Host, ".")[0]
+		if hc != nil && hc.Host == "" && len(r.IPs) != 0 {
+			c := *hc
+			c.Host = r.IPs[0]
+			hc = &c
+		}
+	}
+	name := r.Name
+	if !r.ExactName {
+		name = fmt.Sprintf("%s/%s/%d", r.Name, hostName, r.PortNo)
+	}
+	s := Registration{
+		ID:          r.ID,
+		Name:        name,
+		PortNo:      r.PortNo,
+		ServiceType: r.ServiceType,
+		Subtypes:    r.Subtypes,
+		Text:        r.Text,
+		Domain:      r.Domain,
+		LastContact: time.Now(),
+		Owner:       c.Owner,
+		PID:         r.PID,
+		HealthCheck: hc,
+		Host:        r.Host,
+		IPs:         r.IPs,
+		Source:      source,
+		reg:         g,
+	}
+	return &s
+}
+
+// RegistrationItem returns the details of the service registration
+func (s *Registration) RegistrationItem() api.RegistrationItem {
+	return api.RegistrationItem{
+		ID:          s.ID,
+		Name:        s.Name,
+		PortNo:      s.PortNo,
+		ServiceType: s.ServiceType,
+		Subtypes:    s.Subtypes,
+		Domain:      s.Domain,
+		Text:        s.text(),
+		LastContact: s.LastContact,
+		Owner:       s.Owner,
+		PID:         s.PID,
+		Health:      s.Health(),
+		Announced:   s.IsAnnounced(),
+		Host:        s.Host,
+		IPs:         s.IPs,
+		Source:      s.Source,
+	}
+}
+
+// Event returns a new event of the specified type for the service registration
+func (s *Registration) Event(t string) api.Event {
+	return api.Event{
+		Time:        time.Now(),
+		Type:        t,
+		ID:          s.ID,
+		Name:        s.Name,
+		ServiceType: s.ServiceType,
+		Health:      s.Health(),
+		Announced:   s.IsAnnounced(),
+	}
+}
+
+// IsDifferentFrom returns whether or not the registrations differ in a way that requires
+// the service to be registered again.  Differences in Text can be applied in place.
+func (s *Registration) IsDifferentFrom(i *Registration) bool {
+	if s.ID != i.ID || s.PortNo != i.PortNo || s.Name != i.Name || s.ServiceType != i.ServiceType {
+		return true
+	}
+	if s.Host != i.Host || strings.Join(s.IPs, ",") != strings.Join(i.IPs, ",") || s.Source != i.Source {
+		return true
+	}
+	if strings.Join(s.Subtypes, ",") != strings.Join(i.Subtypes, ",") {
+		return true
+	}
+	return !s.HealthCheck.Equals(i.HealthCheck)
+}
+
+// IsTextDifferentFrom returns whether or not the registrations have different Text
+func (s *Registration) IsTextDifferentFrom(i *Registration) bool {
+	if len(s.Text) != len(i.Text) {
+		return true
+	}
+	for x := range s.Text {
+		if s.Text[x] != i.Text[x] {
+			return true
+		}
+	}
+	return false
+}
+
+// SetText changes the Text of the service and announces the new TXT record
+// without registering the service again
+func (s *Registration) SetText(text []string) {
+	s.lock.Lock()
+	s.Text = text
+	s.lock.Unlock()
+	s.updateText()
+}
+
+// Health returns the current health state, or blank if the service has no health check
+func (s *Registration) Health() string {
+	if s.HealthCheck == nil {
+		return ""
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.health
+}
+
+// LastError returns the error from the last failed announcement, or blank if there was none
+func (s *Registration) LastError() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lastError
+}
+
+// IsAnnounced returns whether the service is currently being announced
+func (s *Registration) IsAnnounced() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.zsrv != nil
+}
+
+// Start registers the service so that it is discoverable
+func (s *Registration) Start() {
+	if s.isRunning {
+		return
+	}
+	s.shutdown = make(chan bool, 1)
+	s.healthCh = make(chan struct{}, 1)
+	s.health = api.HealthUnknown
+	s.isRunning = true
+	s.startWatcher()
+	if s.HealthCheck != nil {
+		s.monitor = NewHealthMonitor(*s.HealthCheck, s.PortNo, s.setHealth)
+	}
+	go s.register()
+}
+
+// Stop deregisters the service so that it is no longer discoverable
+func (s *Registration) Stop() {
+	if !s.isRunning {
+		return
+	}
+	s.stopWatcher()
+	if s.monitor != nil {
+		s.monitor.Stop()
+	}
+	s.shutdown <- true
+}
+
+// SetPID changes the process being watched
+func (s *Registration) SetPID(pid int) {
+	if s.PID == pid {
+		return
+	}
+	s.stopWatcher()
+	s.PID = pid
+	if s.isRunning {
+		s.startWatcher()
+	}
+}
+
+// startWatcher starts watching the registering process, if there is one
+func (s *Registration) startWatcher() {
+	if s.PID <= 0 {
+		return
+	}
+	pid := s.PID
+	s.logDebug("Watching process for service", "pid", pid)
+	var w *ProcessWatcher
+	w = NewProcessWatcher(pid, func() {
+		s.logInfo("Process for service has exited", "pid", pid)
+		s.reg.processExited(s, w)
+	})
+	s.watcher = w
+	w.Start()
+}
+
+// stopWatcher stops watching the registering process
+func (s *Registration) stopWatcher() {
+	if s.watcher != nil {
+		s.watcher.Stop()
+		s.watcher = nil
+	}
+}
+
+// setHealth records the new health state and signals the registration to act on it
+func (s *Registration) setHealth(h string) {
+	s.lock.Lock()
+	s.health = h
+	s.lock.Unlock()
+	select {
+	case s.healthCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Registration) register() {
+	if s.Domain == "" {
+		s.Domain = "local."
+	}
+
+	defer s.withdraw()
+
+	// Services with a health check are only announced once they are healthy,
+	// unless they are kept announced with their health state in the TXT record
+	if s.monitor == nil || s.HealthCheck.KeepAnnounced {
+		s.announce()
+	}
+	if s.monitor != nil {
+		s.monitor.Start()
+	}
+
+	for {
+		select {
+		case <-s.shutdown:
+			// Service shutdown
+			return
+		case <-s.healthCh:
+			h := s.Health()
+			s.logInfo("Service health changed", "health", h)
+			if h == api.HealthHealthy || s.HealthCheck.KeepAnnounced {
+				s.announce()
+				s.updateText()
+			} else {
+				s.withdraw()
+			}
+			s.reg.publish(s.Event(api.EventHealth))
+		}
+	}
+}
+
+// announce registers the service with zeroconf so that it is discoverable
+func (s *Registration) announce() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.zsrv != nil {
+		return
+	}
+	s.logInfo("Registering service")
+	// Subtypes are passed to zeroconf after the service type, separated by commas
+	service := strings.Join(append([]string{s.ServiceType}, s.Subtypes...), ",")
+	var zsrv *zeroconf.Server
+	var err error
+	if s.Host != "" {
+		zsrv, err = zeroconf.RegisterProxy(s.Name, service, s.Domain, s.PortNo, s.Host, s.IPs, s.textLocked(), nil)
+	} else {
+		zsrv, err = zeroconf.Register(s.Name, service, s.Domain, s.PortNo, s.textLocked(), nil)
+	}
+	if err != nil {
+		s.logError("Failed to register service", LogError, err)
+		s.reg.onError("register", err)
+		s.lastError = err.Error()
+		return
+	}
+	s.zsrv = zsrv
+	s.lastError = ""
+}
+
+// updateText announces the current TXT record of the service, if it is announced
+func (s *Registration) updateText() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.zsrv != nil {
+		s.zsrv.SetText(s.textLocked())
+	}
+}
+
+// text returns the TXT record of the service, including the health state if the service has a health check
+func (s *Registration) text() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.textLocked()
+}
+
+// textLocked returns the TXT record of the service.  The lock must be held.
+func (s *Registration) textLocked() []string {
+	if s.HealthCheck == nil {
+		return s.Text
+	}
+	t := []string{}
+	for _, v := range s.Text {
+		if api.TextKey(v) != api.HealthTextKey {
+			t = append(t, v)
+		}
+	}
+	return append(t, api.HealthTextKey+"="+s.health)
+}
+
+// withdraw removes the zeroconf registration so that the service is no longer discoverable
+func (s *Registration) withdraw() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.zsrv == nil {
+		return
+	}
+	s.logInfo("Withdrawing service")
+	s.zsrv.Shutdown()
+	s.zsrv = nil
+}
+
+// logDebug logs a debug message and key/value fields to the logger
+func (s *Registration) logDebug(msg string, args ...interface{}) {
+	s.log().Debug(msg, args...)
+}
+
+// logInfo logs an information message and key/value fields to the logger
+func (s *Registration) logInfo(msg string, args ...interface{}) {
+	s.log().Info(msg, args...)
+}
+
+// logError logs an error message and key/value fields to the logger
+func (s *Registration) logError(msg string, args ...interface{}) {
+	s.log().Error(msg, args...)
+}
+
+// log returns the logger for this service registration
+func (s *Registration) log() *slog.Logger {
+	return s.reg.log("Registration").With(LogRegistrationID, s.ID, LogServiceType, s.ServiceType, "name", s.Name)
+}
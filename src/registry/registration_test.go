@@ -0,0 +1,27 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+func TestNewRegistrationName(t *testing.T) {
+	tests := []struct {
+		name string
+		req  api.RegisterRequest
+		want string
+	}{
+		{"host name and port added", api.RegisterRequest{ID: "1", Name: "web", PortNo: 80}, "web/myhost/80"},
+		{"other machine named after it", api.RegisterRequest{ID: "1", Name: "web", PortNo: 80, Host: "printer.local", IPs: []string{"10.0.0.9"}}, "web/printer/80"},
+		{"exact name", api.RegisterRequest{ID: "1", Name: "Printer on myhost", PortNo: 631, ExactName: true}, "Printer on myhost"},
+	}
+	g := New()
+	g.HostName = "myhost"
+	for _, tt := range tests {
+		r := tt.req
+		if got := g.newRegistration(&r, api.SourceFile, Caller{}).Name; got != tt.want {
+			t.Errorf("%s: Name = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
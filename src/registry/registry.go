@@ -0,0 +1,340 @@
+// Package registry registers services with zeroconf on behalf of their owners, watching the
+// owning processes and running health checks.  It is used by the zcservice daemon, and can be
+// embedded in a service that runs without the daemon.
+package registry
+
+import (
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// DefaultServiceType is the service type used for requests that have none, if the registry has no default
+const DefaultServiceType = "_zcservice._tcp"
+
+// Reasons a service registration is removed
+const (
+	ReasonRequested   = "requested"    // Removed by a /service/remove request
+	ReasonReplaced    = "replaced"     // Replaced by a new registration with the same ID
+	ReasonProcessExit = "process_exit" // Expired because the registering process exited
+	ReasonShutdown    = "shutdown"     // Removed because zcservice is shutting down
+	ReasonReconfigure = "reconfigure"  // Removed because the configuration changed
+)
+
+// Log field names shared by all components
+const (
+	LogComponent      = "component"       // Name of the component writing the log entry
+	LogRegistrationID = "registration_id" // ID of the service registration
+	LogServiceType    = "service_type"    // Service Type
+	LogRequestID      = "request_id"      // ID of the HTTP request
+	LogError          = "error"           // Error message
+)
+
+// Change describes a change to a service registration
+type Change struct {
+	Action string                // Action that was performed, e.g. api.AuditRegister
+	Reason string                // Reason for the change
+	Caller Caller                // Client that made the change
+	ID     string                // ID of the service registration
+	Before *api.RegistrationItem // Registration before the change, if any
+	After  *api.RegistrationItem // Registration after the change, if any
+}
+
+// ServiceType returns the service type of the changed registration
+func (c *Change) ServiceType() string {
+	if c.After != nil {
+		return c.After.ServiceType
+	}
+	if c.Before != nil {
+		return c.Before.ServiceType
+	}
+	return ""
+}
+
+// Registry holds the services registered with zeroconf
+type Registry struct {
+	HostName           string                              // Host name used in the service instance names.  Defaults to the name of the machine
+	DefaultServiceType string                              // Service type of requests that have none.  Defaults to DefaultServiceType
+	OnChange           func(c Change)                      // Called after a registration has changed, e.g. to audit the change
+	OnError            func(op string, err error)          // Called when a zeroconf operation fails
+	Log                func(component string) *slog.Logger // Returns the logger for the named component.  Defaults to slog.Default()
+	regList            map[string]*Registration            // Service registrations by ID
+	regLock            sync.Mutex                          // Mutex lock for appending and removing items from regList
+	events             *EventHub                           // Registration event subscribers
+}
+
+// New creates a new, empty registry
+func New() *Registry {
+	g := &Registry{
+		DefaultServiceType: DefaultServiceType,
+		regList:            make(map[string]*Registration),
+		events:             NewEventHub(),
+	}
+	if hn, err := os.Hostname(); err == nil {
+		g.HostName = hn
+	}
+	return g
+}
+
+// SetDefaultServiceType changes the service type of requests that have none, while services are being registered
+func (g *Registry) SetDefaultServiceType(t string) {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+	g.DefaultServiceType = t
+}
+
+// Register registers the service in the specified request on behalf of the caller.
+// The source is the origin of the registration, e.g. api.SourceAPI.
+func (g *Registry) Register(r *api.RegisterRequest, source string, c Caller) api.RegisterResponse {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	// Check if this service is already registered
+	n := g.newRegistration(r, source, c)
+	e := g.regList[r.ID]
+	addNew := true
+	if e != nil {
+		if n.ID == e.ID {
+			if n.IsDifferentFrom(e) {
+				// Remove the existing one
+				g.logInfo("Deregistering existing service", LogRegistrationID, e.ID, "name", e.Name, LogServiceType, e.ServiceType, LogRequestID, c.RequestID)
+				e.Stop()
+				delete(g.regList, r.ID)
+				g.changed(api.AuditDeregister, ReasonReplaced, c, e, nil)
+			} else {
+				g.logInfo("Confirming existing service", LogRegistrationID, e.ID, "name", e.Name, LogServiceType, e.ServiceType, LogRequestID, c.RequestID)
+				before := e.RegistrationItem()
+				e.LastContact = time.Now()
+				// A renewal without a process ID keeps watching the process of the earlier request
+				if n.PID != 0 {
+					e.SetPID(n.PID)
+				}
+				if n.IsTextDifferentFrom(e) {
+					g.logInfo("Updating text for existing service", LogRegistrationID, e.ID, "name", e.Name, LogServiceType, e.ServiceType, LogRequestID, c.RequestID)
+					e.SetText(n.Text)
+					g.publish(e.Event(api.EventText))
+					g.changedItems(api.AuditText, "registration text changed", c, &before, e)
+				} else {
+					g.changedItems(api.AuditConfirm, "registration confirmed", c, &before, e)
+				}
+				addNew = false
+			}
+		}
+	}
+	if addNew {
+		g.logInfo("Registering new service", LogRegistrationID, n.ID, "name", n.Name, LogServiceType, n.ServiceType, "owner", n.Owner.String(), LogRequestID, c.RequestID)
+		g.regList[r.ID] = n
+		n.Start()
+		reason := "registration requested"
+		switch n.Source {
+		case api.SourceStatic:
+			reason = "static service configured"
+		case api.SourceFile:
+			reason = "service file loaded"
+		}
+		g.changed(api.AuditRegister, reason, c, nil, n)
+		g.publish(n.Event(api.EventRegistered))
+	}
+	return r.CreateResponse()
+}
+
+// UpdateText changes the Text of a registered service without registering it again.
+// Returns false if the service is not registered.
+func (g *Registry) UpdateText(id string, text []string, c Caller) bool {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	e := g.regList[id]
+	if e == nil {
+		return false
+	}
+	g.logInfo("Updating text for existing service", LogRegistrationID, e.ID, "name", e.Name, LogServiceType, e.ServiceType, LogRequestID, c.RequestID)
+	before := e.RegistrationItem()
+	e.LastContact = time.Now()
+	e.SetText(text)
+	g.publish(e.Event(api.EventText))
+	g.changedItems(api.AuditText, "text update requested", c, &before, e)
+	return true
+}
+
+// Renew confirms the service registration without registering it again.
+// Returns false if the service is not registered.
+func (g *Registry) Renew(id string, c Caller) bool {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	e := g.regList[id]
+	if e == nil {
+		return false
+	}
+	before := e.RegistrationItem()
+	e.LastContact = time.Now()
+	g.changedItems(api.AuditConfirm, "registration renewed", c, &before, e)
+	return true
+}
+
+// Deregister removes the service registration for the specified reason
+func (g *Registry) Deregister(id string, reason string, c Caller) {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	// Check to see if this service is already registered
+	e := g.regList[id]
+	if e != nil {
+		if e.ID == id {
+			g.logInfo("Deregistering existing service", LogRegistrationID, e.ID, "name", e.Name, LogServiceType, e.ServiceType, "reason", reason, LogRequestID, c.RequestID)
+			e.Stop()
+			delete(g.regList, id)
+			g.changed(api.AuditDeregister, reason, c, e, nil)
+			g.publish(e.Event(api.EventDeregistered))
+		}
+	}
+}
+
+// Close deregisters all the service registrations
+func (g *Registry) Close() {
+	for _, id := range g.IDs("") {
+		g.Deregister(id, ReasonShutdown, Caller{})
+	}
+}
+
+// processExited deregisters the service registration after the process watched by the watcher has exited
+func (g *Registry) processExited(z *Registration, w *ProcessWatcher) {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	// Make sure the registration has not since been replaced, and is not now watching another process
+	if g.regList[z.ID] == z && z.watcher == w {
+		g.logInfo("Deregistering service as its process has exited", LogRegistrationID, z.ID, "name", z.Name, LogServiceType, z.ServiceType, "pid", z.PID)
+		z.Stop()
+		delete(g.regList, z.ID)
+		g.changed(api.AuditExpire, ReasonProcessExit, Caller{Owner: z.Owner}, z, nil)
+		g.publish(z.Event(api.EventDeregistered))
+	}
+}
+
+// List returns the registered services, ordered by name
+func (g *Registry) List() api.ListResponse {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	resp := api.ListResponse{Services: []api.RegistrationItem{}}
+	for _, z := range g.regList {
+		resp.Services = append(resp.Services, z.RegistrationItem())
+	}
+	sort.Slice(resp.Services, func(i, j int) bool {
+		return resp.Services[i].Name < resp.Services[j].Name
+	})
+	return resp
+}
+
+// Get returns the service registration with the specified ID, or nil if the service is not registered
+func (g *Registry) Get(id string) *Registration {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+	return g.regList[id]
+}
+
+// Source returns the source of the service registration, or blank if the service is not registered
+func (g *Registry) Source(id string) string {
+	if z := g.Get(id); z != nil {
+		return z.Source
+	}
+	return ""
+}
+
+// IDs returns the IDs of the registered services from the specified source, or of all services if it is blank
+func (g *Registry) IDs(source string) []string {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	ids := []string{}
+	for id, z := range g.regList {
+		if source == "" || z.Source == source {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// IsChanged returns whether the service is not registered, or differs from its registration
+// by the specified source
+func (g *Registry) IsChanged(r *api.RegisterRequest, source string) bool {
+	g.regLock.Lock()
+	defer g.regLock.Unlock()
+
+	e := g.regList[r.ID]
+	if e == nil {
+		return true
+	}
+	n := g.newRegistration(r, source, Caller{})
+	return n.IsDifferentFrom(e) || n.IsTextDifferentFrom(e)
+}
+
+// Subscribe returns a channel that receives registration events
+func (g *Registry) Subscribe() chan api.Event {
+	return g.events.Subscribe()
+}
+
+// Unsubscribe stops sending registration events to the channel
+func (g *Registry) Unsubscribe(c chan api.Event) {
+	g.events.Unsubscribe(c)
+}
+
+// publish sends a registration event to the event subscribers
+func (g *Registry) publish(e api.Event) {
+	g.events.Publish(e)
+}
+
+// changed reports a change to a service registration to the OnChange function
+func (g *Registry) changed(action string, reason string, c Caller, before *Registration, after *Registration) {
+	if g.OnChange == nil {
+		return
+	}
+	ch := Change{Action: action, Reason: reason, Caller: c}
+	if before != nil {
+		i := before.RegistrationItem()
+		ch.ID = before.ID
+		ch.Before = &i
+	}
+	if after != nil {
+		i := after.RegistrationItem()
+		ch.ID = after.ID
+		ch.After = &i
+	}
+	g.OnChange(ch)
+}
+
+// changedItems reports a change to a service registration to the OnChange function,
+// using a snapshot of the registration taken before the change
+func (g *Registry) changedItems(action string, reason string, c Caller, before *api.RegistrationItem, after *Registration) {
+	if g.OnChange == nil {
+		return
+	}
+	a := after.RegistrationItem()
+	g.OnChange(Change{Action: action, Reason: reason, Caller: c, ID: after.ID, Before: before, After: &a})
+}
+
+// onError reports a failed zeroconf operation to the OnError function
+func (g *Registry) onError(op string, err error) {
+	if g.OnError != nil {
+		g.OnError(op, err)
+	}
+}
+
+// log returns the logger for the named component
+func (g *Registry) log(component string) *slog.Logger {
+	if g.Log != nil {
+		return g.Log(component)
+	}
+	return slog.Default().With(LogComponent, component)
+}
+
+// logInfo logs an information message and key/value fields to the logger
+func (g *Registry) logInfo(msg string, args ...interface{}) {
+	g.log("Registry").Info(msg, args...)
+}
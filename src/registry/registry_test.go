@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// startSleep starts a process that runs until it is killed, and returns it
+func startSleep(t *testing.T) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skip("cannot start a process to watch: ", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+func TestRegisterRenewalPID(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on Windows")
+	}
+	a := startSleep(t)
+	tests := []struct {
+		name string
+		pid  int
+		want int
+	}{
+		{"renewal without a pid keeps watching", 0, a.Process.Pid},
+		{"renewal with a pid watches it", a.Process.Pid, a.Process.Pid},
+	}
+	for _, tt := range tests {
+		g := New()
+		g.Register(&api.RegisterRequest{ID: "1", Name: "web", PortNo: 80, PID: a.Process.Pid}, api.SourceAPI, Caller{})
+		g.Register(&api.RegisterRequest{ID: "1", Name: "web", PortNo: 80, PID: tt.pid}, api.SourceAPI, Caller{})
+		z := g.Get("1")
+		if z == nil || z.PID != tt.want || z.watcher == nil {
+			t.Errorf("%s: registration = %+v, want pid %d watched", tt.name, z, tt.want)
+		}
+		g.Close()
+	}
+}
+
+func TestProcessExitedAfterPIDChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on Windows")
+	}
+	a := startSleep(t)
+	b := startSleep(t)
+	g := New()
+	defer g.Close()
+	g.Register(&api.RegisterRequest{ID: "1", Name: "web", PortNo: 80, PID: a.Process.Pid}, api.SourceAPI, Caller{})
+	z := g.Get("1")
+	old := z.watcher
+
+	// The renewal changes the process just as the exit of the first process is reported
+	g.Register(&api.RegisterRequest{ID: "1", Name: "web", PortNo: 80, PID: b.Process.Pid}, api.SourceAPI, Caller{})
+	g.processExited(z, old)
+	if g.Get("1") == nil {
+		t.Fatal("registration was removed when the process it no longer watches exited")
+	}
+
+	// The exit of the process now watched removes the registration
+	b.Process.Kill()
+	b.Wait()
+	deadline := time.Now().Add(5 * time.Second)
+	for g.Get("1") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("registration was not removed when its process exited")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
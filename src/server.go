@@ -1,34 +1,59 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/grandcat/zeroconf"
-
 	"github.com/gorilla/mux"
 	"github.com/kardianos/service"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/discovery"
+	"github.com/Brumawen/zcservice/src/registry"
 )
 
 // Server defines the web server
 type Server struct {
-	PortNo   int                  // Port number the server will listen on
-	WaitTime int                  // Duration in secs to wait for replies when discovering services
-	Debug    bool                 // Indicates whether the server is running in debug
-	Config   *Config              // Configuration settings
-	exit     chan struct{}        // Exit flag
-	shutdown chan struct{}        // Shutdown complete flag
-	http     *http.Server         // HTTP server
-	router   *mux.Router          // HTTP router
-	regList  map[string]*ZCServer // Zeroconf registration server list
-	regLock  sync.Mutex           // Mutex lock for appending and removing items from regList
-	hostName string               // HostName of computer
+	PortNo     int                     // Port number the server will listen on
+	WaitTime   int                     // Duration in secs to wait for replies when discovering services
+	Debug      bool                    // Indicates whether the server is running in debug
+	ConfigPath string                  // Path of the configuration file.  Defaults to DefaultConfigPath()
+	StateDir   string                  // Folder to keep state files in.  Defaults to DefaultStateDir()
+	config     atomic.Pointer[Config]  // Configuration settings, replaced as a whole when they are reloaded
+	exit       chan struct{}           // Exit flag
+	shutdown   chan struct{}           // Shutdown complete flag
+	listeners  []*Listener             // HTTP listeners
+	grpcLns    []*GRPCListener         // gRPC listeners
+	sdFiles    []*PrometheusFileWriter // Prometheus file_sd file writers
+	router     *mux.Router             // HTTP router
+	Registry   *registry.Registry      // Registered services
+	audit      *AuditLog               // Audit log of registration and configuration changes
+	cfgWatch   *FileWatcher            // Watches the configuration file for changes
+	cfgLock    sync.Mutex              // Mutex lock for reloading the configuration
+	svcWatch   *FileWatcher            // Watches the services folder for changes
+	svcFiles   map[string][]string     // IDs of the services read from each file in the services folder
+	svcLock    sync.Mutex              // Mutex lock for reading the services folder
+	startTime  time.Time               // Date and time the service started
+	selfTest   *api.HealthResult       // Cached result of the last self browse test
+	selfTime   time.Time               // Date and time of the last self browse test
+	selfLock   sync.Mutex              // Mutex lock for the self browse test
+}
+
+// Config returns the current configuration settings.  The settings are replaced, not changed,
+// when the configuration is reloaded, so a request should get them once and use that copy throughout.
+func (s *Server) Config() *Config {
+	return s.config.Load()
 }
 
 // AddController adds the specified web service controller to the Router
@@ -40,26 +65,33 @@ func (s *Server) addController(c Controller) {
 func (s *Server) Start(v service.Service) error {
 	s.logInfo("Service starting")
 
-	s.regList = make(map[string]*ZCServer)
+	s.Registry = registry.New()
+	s.Registry.OnChange = s.registrationChanged
+	s.Registry.OnError = func(op string, err error) { mdnsErrorsTotal.WithLabelValues(op).Inc() }
+	s.Registry.Log = componentLog
+	s.startTime = time.Now()
 
-	// Make sure the working directory is the same as the application exe
-	ap, err := os.Executable()
-	if err != nil {
-		s.logError("Error getting the executable path.", err.Error())
-	} else {
-		wd, err := os.Getwd()
-		if err != nil {
-			s.logError("Error getting current working directory.", err.Error())
-		} else {
-			ad := filepath.Dir(ap)
-			s.logInfo("Current application path is", ad)
-			if ad != wd {
-				if err := os.Chdir(ad); err != nil {
-					s.logError("Error chaning working directory.", err.Error())
-				}
-			}
-		}
+	// Work out where the configuration and state files are kept
+	if s.ConfigPath == "" {
+		s.ConfigPath = DefaultConfigPath()
+	}
+	if s.StateDir == "" {
+		s.StateDir = DefaultStateDir()
+	}
+	s.logInfo("Using configuration file", "path", s.ConfigPath, "state_dir", s.StateDir)
+
+	// Get the configuration.  The service does not start with a configuration that has errors,
+	// in the same way that a reload does not apply one.
+	cfg := &Config{}
+	if err := cfg.Load(s.ConfigPath); err != nil {
+		s.logError("Failed to read configuration", "path", s.ConfigPath, LogError, err)
+		return fmt.Errorf("invalid configuration file %s: %w", s.ConfigPath, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		s.logError("Invalid configuration", "path", s.ConfigPath, LogError, err)
+		return fmt.Errorf("invalid configuration file %s: %w", s.ConfigPath, err)
 	}
+	s.config.Store(cfg)
 
 	// Create a channel that will be used to block until the Stop signal is received
 	s.exit = make(chan struct{})
@@ -84,93 +116,269 @@ func (s *Server) Shutdown() error {
 }
 
 // GetServiceList searches for services based on the search criteria passed in the request
-func (s *Server) GetServiceList(r GetRequest) (GetResponse, error) {
+func (s *Server) GetServiceList(r api.GetRequest) (api.GetResponse, error) {
 	if s.WaitTime <= 0 {
-		s.WaitTime = 3
+		s.WaitTime = discovery.DefaultWaitTime
 	}
-	wt := r.WaitTime
-	if wt <= 0 {
-		wt = s.WaitTime
+	if r.WaitTime <= 0 {
+		r.WaitTime = s.WaitTime
 	}
-
 	if r.ServiceType == "" {
-		r.ServiceType = s.Config.DefaultServiceType
-	}
-	if r.Domain == "" {
-		r.Domain = "local"
+		r.ServiceType = s.Config().DefaultServiceType
 	}
 
-	resp := r.CreateResponse()
-	resolver, err := zeroconf.NewResolver(nil)
+	start := time.Now()
+	browseTotal.WithLabelValues(r.ServiceType).Inc()
+	resp, err := discovery.Browse(context.Background(), r)
 	if err != nil {
-		s.logError("Failed to initialize zeroconf resolver.", err.Error())
+		s.logError("Failed to browse for services", LogServiceType, r.ServiceType, "domain", resp.Domain, LogError, err)
+		s.mdnsError(err)
 		return resp, err
 	}
-	entries := make(chan *zeroconf.ServiceEntry)
-	go func(results <-chan *zeroconf.ServiceEntry) {
-		for entry := range results {
-			resp.Services = append(resp.Services, NewServiceItemFromZeroConf(entry))
-		}
-	}(entries)
+	browseDuration.Observe(time.Since(start).Seconds())
+	browseResults.Observe(float64(len(resp.Services)))
+	return resp, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(wt))
-	defer cancel()
-	err = resolver.Browse(ctx, r.ServiceType, r.Domain, entries)
-	if err != nil {
-		s.logError(fmt.Sprintf("Failed to browse for services with ServiceType '%s' on Domain '%s'.", r.ServiceType, r.Domain), err.Error())
-		return resp, err
+// RequestError is returned when a request to change a service registration is refused
+type RequestError struct {
+	Status  int    // HTTP status code of the error
+	Message string // Error message
+}
+
+// Error returns the error message
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+// RegisterService checks the request and registers the service on behalf of the caller
+func (s *Server) RegisterService(req *api.RegisterRequest, c registry.Caller) (api.RegisterResponse, *RequestError) {
+	if req.ID == "" {
+		return api.RegisterResponse{}, &RequestError{400, "ID is missing."}
+	}
+	if req.Name == "" {
+		return api.RegisterResponse{}, &RequestError{400, "Service Name is missing."}
+	}
+	if req.PortNo <= 0 {
+		return api.RegisterResponse{}, &RequestError{400, "Invalid Port Number."}
+	}
+	if req.WatchOwner && c.Owner != nil && c.Owner.PID > 0 {
+		req.PID = c.Owner.PID
 	}
+	if src := s.Registry.Source(req.ID); src != "" && src != api.SourceAPI {
+		return api.RegisterResponse{}, &RequestError{409, "Service ID is in use by a " + src + " service."}
+	}
+	if err := req.ValidateProxy(); err != nil {
+		return api.RegisterResponse{}, &RequestError{400, "Invalid Host. " + err.Error()}
+	}
+	if req.PID < 0 || (req.PID > 0 && !registry.ProcessExists(req.PID)) {
+		return api.RegisterResponse{}, &RequestError{400, "Invalid Process ID."}
+	}
+	if err := s.checkHealthCheck(req.HealthCheck); err != nil {
+		return api.RegisterResponse{}, err
+	}
+	return s.Registry.Register(req, api.SourceAPI, c), nil
+}
 
-	<-ctx.Done()
+// checkHealthCheck checks the health check of a registration, refusing script checks unless they are enabled
+func (s *Server) checkHealthCheck(hc *api.HealthCheck) *RequestError {
+	if hc == nil {
+		return nil
+	}
+	if err := hc.Validate(); err != nil {
+		return &RequestError{400, "Invalid Health Check. " + err.Error()}
+	}
+	if hc.Type == "script" && !s.Config().AllowScriptChecks {
+		return &RequestError{403, "Script health checks are not enabled."}
+	}
+	return nil
+}
 
-	return resp, nil
+// CheckDeregister checks that the service registration with the ID can be removed through the API
+func (s *Server) CheckDeregister(id string) *RequestError {
+	if id == "" {
+		return &RequestError{400, "Invalid ID"}
+	}
+	if src := s.Registry.Source(id); src != "" && src != api.SourceAPI {
+		return &RequestError{403, "A " + src + " service cannot be removed."}
+	}
+	return nil
 }
 
-// RegisterService registers the service in the specified request
-func (s *Server) RegisterService(r *RegisterRequest) RegisterResponse {
-	s.regLock.Lock()
-	defer s.regLock.Unlock()
-
-	// Check if this service is already registered
-	n := NewServerFromRequest(r, s)
-	e := s.regList[r.ID]
-	addNew := true
-	if e != nil {
-		if n.ID == e.ID {
-			if n.IsDifferentFrom(e) {
-				// Remove the existing one
-				s.logInfo(fmt.Sprintf("Deregistering existing service %s: %s", e.ID, e.Name))
-				e.Stop()
-				delete(s.regList, r.ID)
-			} else {
-				s.logInfo(fmt.Sprintf("Confirming existing service %s: %s", e.ID, e.Name))
-				e.LastContact = time.Now()
-				addNew = false
-			}
+// UpdateText changes the Text of a service registration made through the API, without registering it again
+func (s *Server) UpdateText(id string, text []string, c registry.Caller) *RequestError {
+	if id == "" {
+		return &RequestError{400, "Invalid ID"}
+	}
+	if src := s.Registry.Source(id); src != "" && src != api.SourceAPI {
+		return &RequestError{403, "A " + src + " service cannot be changed."}
+	}
+	if !s.Registry.UpdateText(id, text, c) {
+		return &RequestError{404, "Service not found."}
+	}
+	return nil
+}
+
+// WatchServices searches for services based on the search criteria passed in the request at the specified
+// interval, and returns a channel that receives an event each time a service instance is found, changes
+// or is no longer found.  The channel is closed when the context is done.
+func (s *Server) WatchServices(ctx context.Context, r api.GetRequest, interval time.Duration) (<-chan api.ServiceEvent, error) {
+	if r.WaitTime <= 0 {
+		r.WaitTime = s.WaitTime
+	}
+	if r.ServiceType == "" {
+		r.ServiceType = s.Config().DefaultServiceType
+	}
+	return discovery.Watch(ctx, r, interval)
+}
+
+// RecordConfigChange records a configuration change in the audit log
+func (s *Server) RecordConfigChange(before interface{}, after interface{}, c registry.Caller, reason string) {
+	s.writeAudit(api.AuditRecord{
+		Action:    api.AuditConfigChange,
+		Caller:    c.Addr,
+		Owner:     c.Owner,
+		RequestID: c.RequestID,
+		Reason:    reason,
+		Before:    before,
+		After:     after,
+	})
+}
+
+// registrationChanged records a change to a service registration in the audit log and the metrics
+func (s *Server) registrationChanged(c registry.Change) {
+	st := c.ServiceType()
+	switch c.Action {
+	case api.AuditRegister:
+		registrationsTotal.WithLabelValues(st).Inc()
+	case api.AuditDeregister:
+		deregistrationsTotal.WithLabelValues(st, c.Reason).Inc()
+	case api.AuditExpire:
+		deregistrationsTotal.WithLabelValues(st, c.Reason).Inc()
+		expiriesTotal.WithLabelValues(st, c.Reason).Inc()
+	}
+	r := api.AuditRecord{
+		Action:    c.Action,
+		ID:        c.ID,
+		Caller:    c.Caller.Addr,
+		Owner:     c.Caller.Owner,
+		RequestID: c.Caller.RequestID,
+		Reason:    c.Reason,
+	}
+	// Leave out nil pointers so that they are not recorded as null
+	if c.Before != nil {
+		r.Before = c.Before
+	}
+	if c.After != nil {
+		r.After = c.After
+	}
+	s.writeAudit(r)
+}
+
+// mdnsError counts a failed zeroconf operation in the metrics
+func (s *Server) mdnsError(err error) {
+	op := "browse"
+	var de *discovery.Error
+	if errors.As(err, &de) {
+		op = de.Op
+	}
+	mdnsErrorsTotal.WithLabelValues(op).Inc()
+}
+
+// writeAudit appends the record to the audit log
+func (s *Server) writeAudit(r api.AuditRecord) {
+	if s.audit == nil {
+		return
+	}
+	if err := s.audit.Record(r); err != nil {
+		s.logError("Failed to write audit record", LogError, err)
+	}
+}
+
+// GetLiveness returns the liveness state of the service
+func (s *Server) GetLiveness() api.HealthResponse {
+	return api.HealthResponse{
+		Status:   "ok",
+		Uptime:   time.Since(s.startTime).Seconds(),
+		Version:  version,
+		ConfigID: s.Config().ID,
+	}
+}
+
+// GetReadiness returns whether the service is able to register and discover services
+func (s *Server) GetReadiness() api.HealthResponse {
+	resp := s.GetLiveness()
+
+	// Check our own registration
+	z := s.Registry.Get(resp.ConfigID)
+	resp.Registration = &api.HealthResult{Status: "ok"}
+	if z == nil {
+		resp.Registration = &api.HealthResult{Status: "fail", Error: "zcservice is not registered"}
+	} else if !z.IsAnnounced() {
+		resp.Registration = &api.HealthResult{Status: "fail", Error: "zcservice is not announced"}
+		if err := z.LastError(); err != "" {
+			resp.Registration.Error = err
 		}
 	}
-	if addNew {
-		s.logInfo(fmt.Sprintf("Registering new service %s: %s", n.ID, n.Name))
-		s.regList[r.ID] = n
-		n.Start()
+
+	// Check the network interfaces
+	resp.Interfaces = []string{}
+	for _, i := range multicastInterfaces() {
+		resp.Interfaces = append(resp.Interfaces, i.Name)
+	}
+
+	// Check that we can discover our own registration
+	if z != nil {
+		resp.SelfBrowse = s.selfBrowse(z)
 	}
-	return r.CreateResponse()
+
+	if resp.Registration.Status != "ok" || len(resp.Interfaces) == 0 ||
+		resp.SelfBrowse == nil || resp.SelfBrowse.Status != "ok" {
+		resp.Status = "fail"
+	}
+	return resp
 }
 
-// DeregisterService removes the service registration
-func (s *Server) DeregisterService(id string) {
-	s.regLock.Lock()
-	defer s.regLock.Unlock()
+// selfBrowse looks up the registration of the zcservice itself over mDNS.
+// The result is cached for a short time so that frequent readiness probes do not flood the network.
+func (s *Server) selfBrowse(z *registry.Registration) *api.HealthResult {
+	s.selfLock.Lock()
+	defer s.selfLock.Unlock()
+	if s.selfTest != nil && time.Since(s.selfTime) < 10*time.Second {
+		return s.selfTest
+	}
+
+	res := &api.HealthResult{Status: "fail"}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if i, err := discovery.Lookup(ctx, z.Name, z.ServiceType, z.Domain); err != nil {
+		res.Error = err.Error()
+	} else if i == nil {
+		res.Error = "zcservice registration was not found"
+	} else {
+		res.Status = "ok"
+	}
+	s.selfTest = res
+	s.selfTime = time.Now()
+	return res
+}
 
-	// Check to see if this service is already registered
-	e := s.regList[id]
-	if e != nil {
-		if e.ID == id {
-			s.logInfo(fmt.Sprintf("Deregistering existing service %s: %s", e.ID, e.Name))
-			e.Stop()
-			delete(s.regList, id)
+// multicastInterfaces returns the network interfaces that are up and support multicast
+func multicastInterfaces() []net.Interface {
+	l := []net.Interface{}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return l
+	}
+	for _, i := range ifaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if a, err := i.Addrs(); err == nil && len(a) != 0 {
+			l = append(l, i)
 		}
 	}
+	return l
 }
 
 func (s *Server) run() {
@@ -178,11 +386,9 @@ func (s *Server) run() {
 		s.PortNo = 20404
 	}
 
-	// Get the configuration
-	if s.Config == nil {
-		s.Config = &Config{}
-	}
-	s.Config.ReadFromFile("config.json")
+	cfg := s.Config()
+	s.configureLog()
+	s.audit = NewAuditLog(s.auditConfig())
 
 	// Create a router
 	s.router = mux.NewRouter().StrictSlash(true)
@@ -190,71 +396,293 @@ func (s *Server) run() {
 	// Add the controllers
 	s.addController(new(ServiceController))
 	s.addController(new(OnlineController))
-
-	// Create an HTTP server
-	// We lock to the loopback so that this service is not visible externally
-	s.http = &http.Server{
-		Addr:    fmt.Sprintf("127.0.0.1:%d", s.PortNo),
-		Handler: s.router,
-	}
+	s.addController(new(MetricsController))
+	s.addController(new(HealthController))
+	s.addController(new(AdminController))
+	s.addController(new(AuditController))
+	s.addController(new(ProxyController))
+	s.addController(new(ConsulController))
 
 	// Register this service
-	if hn, err := os.Hostname(); err != nil {
-		s.hostName = s.Config.ID
-	} else {
-		s.hostName = hn
+	if s.Registry.HostName == "" {
+		s.Registry.HostName = cfg.ID
+	}
+	s.Registry.DefaultServiceType = cfg.DefaultServiceType
+	s.registerSelf()
+	s.syncStaticServices(registry.Caller{})
+	s.watchServiceFiles()
+	s.syncServiceFiles(registry.Caller{})
+
+	// Start the web server listeners
+	s.startListeners()
+	s.startSDFiles()
+
+	// Watch the configuration file for changes
+	s.watchConfig()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	// Wait for an exit signal, reloading the configuration on SIGHUP
+	for wait := true; wait; {
+		select {
+		case <-s.exit:
+			wait = false
+		case <-hup:
+			s.reloadConfig(registry.Caller{}, "SIGHUP received")
+		}
 	}
-	s.RegisterService(&RegisterRequest{
-		ID:          s.Config.ID,
-		Name:        s.Config.Name,
+	signal.Stop(hup)
+	if s.cfgWatch != nil {
+		s.cfgWatch.Stop()
+	}
+
+	// Shutdown the HTTP and gRPC listeners.  The lock is held so that a reload that
+	// is still in progress cannot start them again.
+	s.cfgLock.Lock()
+	s.stopServiceFiles()
+	s.stopListeners()
+	s.stopSDFiles()
+	s.cfgLock.Unlock()
+
+	// Shutdown the registered services
+	s.logDebug("Deregistering service registrations")
+	s.Registry.Close()
+
+	s.audit.Close()
+
+	s.logDebug("Shutdown complete")
+	close(s.shutdown)
+}
+
+// registerSelf registers this zcservice instance using the current configuration
+func (s *Server) registerSelf() {
+	cfg := s.Config()
+	s.Registry.Register(&api.RegisterRequest{
+		ID:          cfg.ID,
+		Name:        cfg.Name,
 		PortNo:      s.PortNo,
-		ServiceType: s.Config.DefaultServiceType,
-		Text:        []string{fmt.Sprintf("id=%s", s.Config.ID)},
-	})
+		ServiceType: cfg.DefaultServiceType,
+		Text:        []string{fmt.Sprintf("id=%s", cfg.ID)},
+	}, api.SourceSelf, registry.Caller{})
+}
 
-	// Start the web server
-	go func() {
-		s.logInfo("Server listening on port", s.PortNo)
-		if err := s.http.ListenAndServe(); err != nil {
-			msg := err.Error()
-			if !strings.Contains(msg, "http: Server closed") {
-				s.logError("Error starting Web Server.", err.Error())
+// ReloadConfig reads the configuration file again, validates it and applies any changes
+// without disturbing the registered services
+func (s *Server) ReloadConfig(c registry.Caller, reason string) error {
+	s.cfgLock.Lock()
+	defer s.cfgLock.Unlock()
+	if s.isStopping() {
+		return errors.New("the service is stopping")
+	}
+
+	if _, err := os.Stat(s.ConfigPath); err != nil {
+		return err
+	}
+	nc := &Config{}
+	if err := nc.Load(s.ConfigPath); err != nil {
+		return err
+	}
+	if err := nc.Validate(); err != nil {
+		return err
+	}
+	oc := s.Config()
+	if configEqual(oc, nc) {
+		s.logDebug("Configuration is unchanged")
+		return nil
+	}
+
+	s.logInfo("Applying configuration changes", "reason", reason, LogRequestID, c.RequestID)
+	s.config.Store(nc)
+	s.Registry.SetDefaultServiceType(nc.DefaultServiceType)
+	s.configureLog()
+	s.audit.SetConfig(s.auditConfig())
+
+	// Announce this zcservice instance under its new name, type or ID
+	if oc.ID != nc.ID {
+		s.Registry.Deregister(oc.ID, registry.ReasonReconfigure, c)
+	}
+	if oc.ID != nc.ID || oc.Name != nc.Name || oc.DefaultServiceType != nc.DefaultServiceType {
+		s.registerSelf()
+	}
+	s.syncStaticServices(c)
+	s.watchServiceFiles()
+	s.syncServiceFiles(c)
+
+	if !configEqual(oc.Prometheus, nc.Prometheus) {
+		s.stopSDFiles()
+		s.startSDFiles()
+	}
+
+	// Restart the listeners if they have changed.  This is done in the background as
+	// the reload may have been requested through one of the listeners.
+	if !configEqual(oc.Listeners, nc.Listeners) || !configEqual(oc.GRPC, nc.GRPC) {
+		go func() {
+			s.cfgLock.Lock()
+			defer s.cfgLock.Unlock()
+			if s.isStopping() {
+				return
 			}
-		}
-	}()
+			s.stopListeners()
+			s.startListeners()
+		}()
+	}
 
-	// Wait for an exit signal
-	_ = <-s.exit
+	s.RecordConfigChange(oc.Redacted(), nc.Redacted(), c, reason)
+	return nil
+}
 
-	// Shutdown the HTTP server
-	s.http.Shutdown(nil)
+// isStopping returns whether the service has been asked to stop
+func (s *Server) isStopping() bool {
+	select {
+	case <-s.exit:
+		return true
+	default:
+		return false
+	}
+}
 
-	// Shutdown the registered services
-	s.logDebug("Deregistering service registrations.")
-	for _, i := range s.regList {
-		s.DeregisterService(i.ID)
+// reloadConfig reloads the configuration, logging any errors
+func (s *Server) reloadConfig(c registry.Caller, reason string) {
+	if err := s.ReloadConfig(c, reason); err != nil {
+		s.logError("Failed to reload configuration", "reason", reason, LogError, err)
 	}
+}
 
-	s.logDebug("Shutdown complete")
-	close(s.shutdown)
+// watchConfig starts watching the configuration file for changes
+func (s *Server) watchConfig() {
+	p, err := filepath.Abs(s.ConfigPath)
+	if err != nil {
+		s.logError("Failed to watch configuration file", LogError, err)
+		return
+	}
+	name := filepath.Base(p)
+	s.cfgWatch = &FileWatcher{
+		Dir:   filepath.Dir(p),
+		Match: func(n string) bool { return n == name },
+		OnChange: func() {
+			s.reloadConfig(registry.Caller{}, "configuration file changed")
+		},
+	}
+	if err := s.cfgWatch.Start(); err != nil {
+		s.logError("Failed to watch configuration file", LogError, err)
+		s.cfgWatch = nil
+	}
+}
+
+// auditConfig returns the audit log configuration, with the file path relative to the state folder
+func (s *Server) auditConfig() AuditConfig {
+	c := s.Config().Audit
+	c.File = resolvePath(s.StateDir, c.File)
+	return c
+}
+
+// configFile returns the path of a file named in the configuration, relative to the configuration folder
+func (s *Server) configFile(p string) string {
+	return resolvePath(filepath.Dir(s.ConfigPath), p)
+}
+
+// configEqual returns whether the serialized configuration values are the same
+func configEqual(a interface{}, b interface{}) bool {
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ab, bb)
+}
+
+// stopListeners stops the HTTP and gRPC listeners
+func (s *Server) stopListeners() {
+	for _, l := range s.listeners {
+		l.Stop()
+	}
+	s.listeners = nil
+	for _, l := range s.grpcLns {
+		l.Stop()
+	}
+	s.grpcLns = nil
+}
+
+// startListeners starts the HTTP and gRPC listeners defined in the configuration
+func (s *Server) startListeners() {
+	cfg := s.Config()
+	lc := cfg.Listeners
+	if len(lc) == 0 {
+		// We lock to the loopback so that this service is not visible externally
+		lc = []ListenerConfig{{Address: fmt.Sprintf("127.0.0.1:%d", s.PortNo)}}
+	}
+	s.listeners = nil
+	for _, c := range lc {
+		l := &Listener{Config: c, Srv: s}
+		if err := l.Start(); err != nil {
+			s.logError("Error starting listener", "address", c.Address, LogError, err)
+			continue
+		}
+		s.listeners = append(s.listeners, l)
+	}
+	s.grpcLns = nil
+	for _, c := range cfg.GRPC {
+		l := &GRPCListener{Config: c, Srv: s}
+		if err := l.Start(); err != nil {
+			s.logError("Error starting gRPC listener", "address", c.Address, LogError, err)
+			continue
+		}
+		s.grpcLns = append(s.grpcLns, l)
+	}
+}
+
+// stopSDFiles stops keeping the Prometheus file_sd files up to date
+func (s *Server) stopSDFiles() {
+	for _, w := range s.sdFiles {
+		w.Stop()
+	}
+	s.sdFiles = nil
+}
+
+// startSDFiles starts keeping the Prometheus file_sd files defined in the configuration up to date
+func (s *Server) startSDFiles() {
+	s.sdFiles = nil
+	for _, c := range s.Config().Prometheus.Files {
+		w := &PrometheusFileWriter{Config: c, Path: resolvePath(s.StateDir, c.Path), Srv: s}
+		if err := w.Start(); err != nil {
+			s.logError("Error starting Prometheus file", "path", c.Path, LogError, err)
+			continue
+		}
+		s.sdFiles = append(s.sdFiles, w)
+	}
 }
 
-// logDebug logs a debug message to the logger
-func (s *Server) logDebug(v ...interface{}) {
-	if s.Debug {
-		a := fmt.Sprint(v)
-		logger.Info("Server: [Dbg] ", a[1:len(a)-1])
+// configureLog applies the logging configuration
+func (s *Server) configureLog() {
+	cfg := s.Config()
+	level := cfg.LogLevel
+	if level == "" && s.Debug {
+		level = "debug"
 	}
+	if err := ConfigureLog(level, cfg.LogFormat); err != nil {
+		s.logError("Invalid logging configuration", LogError, err)
+	}
+}
+
+// logDebug logs a debug message and key/value fields to the logger
+func (s *Server) logDebug(msg string, args ...interface{}) {
+	componentLog("Server").Debug(msg, args...)
+}
+
+// logInfo logs an information message and key/value fields to the logger
+func (s *Server) logInfo(msg string, args ...interface{}) {
+	componentLog("Server").Info(msg, args...)
 }
 
-// logInfo logs an information message to the logger
-func (s *Server) logInfo(v ...interface{}) {
-	a := fmt.Sprint(v)
-	logger.Info("Server: [Inf] ", a[1:len(a)-1])
+// logWarn logs a warning message and key/value fields to the logger
+func (s *Server) logWarn(msg string, args ...interface{}) {
+	componentLog("Server").Warn(msg, args...)
 }
 
-// logError logs an error message to the logger
-func (s *Server) logError(v ...interface{}) {
-	a := fmt.Sprint(v)
-	logger.Error("Server: [Err] ", a[1:len(a)-1])
+// logError logs an error message and key/value fields to the logger
+func (s *Server) logError(msg string, args ...interface{}) {
+	componentLog("Server").Error(msg, args...)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+func TestCheckHealthCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		hc     *api.HealthCheck
+		allow  bool
+		status int
+	}{
+		{"no health check", nil, false, 0},
+		{"tcp check", &api.HealthCheck{Type: "tcp"}, false, 0},
+		{"script check not allowed", &api.HealthCheck{Type: "script", Script: "/bin/true"}, false, 403},
+		{"script check allowed", &api.HealthCheck{Type: "script", Script: "/bin/true"}, true, 0},
+		{"invalid check", &api.HealthCheck{Type: "ping"}, true, 400},
+	}
+	for _, tt := range tests {
+		s := &Server{}
+		s.config.Store(&Config{AllowScriptChecks: tt.allow})
+		status := 0
+		if err := s.checkHealthCheck(tt.hc); err != nil {
+			status = err.Status
+		}
+		if status != tt.status {
+			t.Errorf("%s: checkHealthCheck() status = %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
+
+func TestRegisterDefinitionRefusesScriptChecks(t *testing.T) {
+	s := &Server{Registry: registry.New()}
+	s.config.Store(&Config{})
+	for _, source := range []string{api.SourceStatic, api.SourceFile} {
+		def := &ServiceDefinition{ID: "legacy", Name: "legacy", PortNo: 8080,
+			HealthCheck: &api.HealthCheck{Type: "script", Script: "/usr/bin/id"}}
+		if err := s.registerDefinition(def, source, registry.Caller{}); err == nil {
+			t.Errorf("%s: registerDefinition() allowed a script check", source)
+		}
+		if s.Registry.Get("legacy") != nil {
+			t.Errorf("%s: service with a script check was registered", source)
+		}
+	}
+}
+
+func TestRegisterDefinitionRefusesOtherSources(t *testing.T) {
+	s := &Server{Registry: registry.New()}
+	defer s.Registry.Close()
+	s.config.Store(&Config{ID: "self"})
+	s.Registry.Register(&api.RegisterRequest{ID: "self", Name: "ZCService", PortNo: 20404}, api.SourceSelf, registry.Caller{})
+	s.Registry.Register(&api.RegisterRequest{ID: "live", Name: "live", PortNo: 8080}, api.SourceAPI, registry.Caller{})
+
+	tests := []struct {
+		id     string
+		source string
+		want   string
+	}{
+		{"self", api.SourceStatic, api.SourceSelf},
+		{"live", api.SourceStatic, api.SourceAPI},
+		{"self", api.SourceFile, api.SourceSelf},
+		{"live", api.SourceFile, api.SourceAPI},
+	}
+	for _, tt := range tests {
+		def := &ServiceDefinition{ID: tt.id, Name: "legacy", PortNo: 9000}
+		err := s.registerDefinition(def, tt.source, registry.Caller{})
+		if re, ok := err.(*RequestError); !ok || re.Status != 409 {
+			t.Errorf("%s %s: registerDefinition() error = %v, want 409", tt.source, tt.id, err)
+		}
+		if got := s.Registry.Source(tt.id); got != tt.want {
+			t.Errorf("%s %s: source = %q, want %q", tt.source, tt.id, got, tt.want)
+		}
+		if got := s.Registry.Get(tt.id).PortNo; got == 9000 {
+			t.Errorf("%s %s: registration was replaced", tt.source, tt.id)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
 )
 
 // ServiceController handles the web methods for registering and discovering services
@@ -21,10 +25,18 @@ func (c *ServiceController) AddController(router *mux.Router, s *Server) {
 		Handler(Logger(c, http.HandlerFunc(c.handleAdd)))
 	router.Methods("DELETE").Path("/service/remove/{id}").
 		Handler(Logger(c, http.HandlerFunc(c.handleRemove)))
+	router.Methods("PATCH").Path("/service/{id}/text").
+		Handler(Logger(c, http.HandlerFunc(c.handleText)))
+	router.Methods("GET").Path("/service/list").
+		Handler(Logger(c, http.HandlerFunc(c.handleList)))
+	router.Methods("GET").Path("/service/events").
+		Handler(Logger(c, http.HandlerFunc(c.handleEvents)))
+	router.Methods("GET").Path("/service/watch").
+		Handler(Logger(c, http.HandlerFunc(c.handleWatch)))
 }
 
 func (c *ServiceController) handleGet(w http.ResponseWriter, r *http.Request) {
-	req := GetRequest{}
+	req := api.GetRequest{}
 	if r.ContentLength != 0 {
 		req.ReadFrom(r.Body)
 	}
@@ -36,36 +48,112 @@ func (c *ServiceController) handleGet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *ServiceController) handleAdd(w http.ResponseWriter, r *http.Request) {
-	req := RegisterRequest{}
+	req := api.RegisterRequest{}
 	req.ReadFrom(r.Body)
-	if req.ID == "" {
-		http.Error(w, "ID is missing.", 400)
-		return
-	}
-	if req.Name == "" {
-		http.Error(w, "Service Name is missing.", 400)
-		return
-	}
-	if req.PortNo <= 0 {
-		http.Error(w, "Invalid Port Number.", 400)
+	resp, err := c.Srv.RegisterService(&req, CallerFromRequest(r))
+	if err != nil {
+		http.Error(w, err.Message, err.Status)
 		return
 	}
-	resp := c.Srv.RegisterService(&req)
 	resp.WriteTo(w)
 }
 
 func (c *ServiceController) handleRemove(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
-	if id == "" {
-		http.Error(w, "Invalid ID", 400)
+	if err := c.Srv.CheckDeregister(id); err != nil {
+		http.Error(w, err.Message, err.Status)
 	} else {
-		go c.Srv.DeregisterService(id)
+		go c.Srv.Registry.Deregister(id, registry.ReasonRequested, CallerFromRequest(r))
+	}
+}
+
+func (c *ServiceController) handleText(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	req := api.TextRequest{}
+	if err := req.ReadFrom(r.Body); err != nil {
+		http.Error(w, "Invalid Text. "+err.Error(), 400)
+		return
+	}
+	if err := c.Srv.UpdateText(id, req.Text, CallerFromRequest(r)); err != nil {
+		http.Error(w, err.Message, err.Status)
+		return
+	}
+	resp := api.RegisterResponse{ID: id}
+	resp.WriteTo(w)
+}
+
+func (c *ServiceController) handleList(w http.ResponseWriter, r *http.Request) {
+	resp := c.Srv.Registry.List()
+	resp.WriteTo(w)
+}
+
+func (c *ServiceController) handleEvents(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported.", 500)
+		return
+	}
+	events := c.Srv.Registry.Subscribe()
+	defer c.Srv.Registry.Unsubscribe(events)
+
+	w.Header().Set("content-type", "application/x-ndjson")
+	w.WriteHeader(200)
+	f.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			if v, err := e.Serialize(); err == nil {
+				w.Write([]byte(v + "\n"))
+				f.Flush()
+			}
+		}
+	}
+}
+
+func (c *ServiceController) handleWatch(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported.", 500)
+		return
+	}
+	q := r.URL.Query()
+	req := api.GetRequest{
+		ServiceType: q.Get("type"),
+		Domain:      q.Get("domain"),
+		OnlyHealthy: q.Get("onlyHealthy") == "true",
+	}
+	req.SetDefaults()
+	interval := 0
+	if v := q.Get("interval"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil || i <= 0 {
+			http.Error(w, "Invalid Interval.", 400)
+			return
+		}
+		interval = i
+	}
+	events, err := c.Srv.WatchServices(r.Context(), req, time.Duration(interval)*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	w.Header().Set("content-type", "application/x-ndjson")
+	w.WriteHeader(200)
+	f.Flush()
+	for e := range events {
+		if v, err := e.Serialize(); err == nil {
+			w.Write([]byte(v + "\n"))
+			f.Flush()
+		}
 	}
 }
 
-// LogInfo is used to log information messages for this controller.
-func (c *ServiceController) LogInfo(v ...interface{}) {
-	a := fmt.Sprint(v)
-	logger.Info("ServiceController: [Inf] ", a[1:len(a)-1])
+// LogInfo is used to log information messages and key/value fields for this controller.
+func (c *ServiceController) LogInfo(msg string, args ...interface{}) {
+	componentLog("ServiceController").Info(msg, args...)
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+// isServiceFile returns whether the named file in the services folder is a service definition.
+// Hidden files are skipped, as editors and package managers use them for temporary files.
+func isServiceFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml", ".toml", ".service":
+		return true
+	}
+	return false
+}
+
+// readServiceFile reads the service definitions from the file.  Avahi .service files
+// may define several services, and the other formats define one.
+func readServiceFile(path string) ([]ServiceDefinition, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".service" {
+		return readAvahiServiceFile(path)
+	}
+	def, err := readServiceDefinition(path)
+	if err != nil {
+		return nil, err
+	}
+	return []ServiceDefinition{*def}, nil
+}
+
+// readServiceDefinition reads the service definition from the file, checking it against the
+// schema of the static services in the configuration.  If the definition has no ID, the
+// ID is derived from the file name so that it stays the same as the file is changed.
+func readServiceDefinition(path string) (*ServiceDefinition, error) {
+	d, err := readConfigDoc(path)
+	if err != nil {
+		return nil, err
+	}
+	errs := configSchema.Properties["services"].Items.Validate(d)
+	def := &ServiceDefinition{}
+	if err := d.decode(def); err != nil && len(errs) == 0 {
+		errs = append(errs, &ConfigError{Message: err.Error()})
+	}
+	if len(errs) != 0 {
+		errs.sort()
+		return nil, errs
+	}
+	if def.ID == "" {
+		h := sha1.Sum([]byte(filepath.Base(path)))
+		def.ID = hex.EncodeToString(h[:16])
+	}
+	if err := def.Validate(); err != nil {
+		return nil, ConfigErrors{{Message: err.Error()}}
+	}
+	return def, nil
+}
+
+// CheckServiceFiles reads each file in the services folder named in the configuration file,
+// and returns the errors found, keyed by the path of the file
+func CheckServiceFiles(configPath string) map[string]error {
+	c := &Config{}
+	if d, err := readConfigDoc(configPath); err == nil {
+		d.decode(c)
+	}
+	dir := servicesDir(configPath, c.ServicesDir)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return map[string]error{dir: err}
+	}
+	errs := map[string]error{}
+	for _, fi := range entries {
+		if fi.IsDir() || !isServiceFile(fi.Name()) {
+			continue
+		}
+		p := filepath.Join(dir, fi.Name())
+		if _, err := readServiceFile(p); err != nil {
+			errs[p] = err
+		}
+	}
+	return errs
+}
+
+// servicesDir returns the services folder named in the configuration, relative to the configuration folder
+func servicesDir(configPath string, dir string) string {
+	if dir == "" {
+		dir = "services.d"
+	}
+	return resolvePath(filepath.Dir(configPath), dir)
+}
+
+// servicesDir returns the folder service definition files are read from
+func (s *Server) servicesDir() string {
+	return servicesDir(s.ConfigPath, s.Config().ServicesDir)
+}
+
+// watchServiceFiles starts watching the services folder for changes, creating it if it does not exist
+func (s *Server) watchServiceFiles() {
+	dir := s.servicesDir()
+	if s.svcWatch != nil && s.svcWatch.Dir == dir {
+		return
+	}
+	s.stopServiceFiles()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		s.logWarn("Failed to create services folder", "dir", dir, LogError, err)
+		return
+	}
+	s.svcWatch = &FileWatcher{
+		Dir:   dir,
+		Match: isServiceFile,
+		OnChange: func() {
+			s.syncServiceFiles(registry.Caller{})
+		},
+	}
+	if err := s.svcWatch.Start(); err != nil {
+		s.logError("Failed to watch services folder", "dir", dir, LogError, err)
+		s.svcWatch = nil
+	}
+}
+
+// stopServiceFiles stops watching the services folder
+func (s *Server) stopServiceFiles() {
+	if s.svcWatch != nil {
+		s.svcWatch.Stop()
+		s.svcWatch = nil
+	}
+}
+
+// syncServiceFiles registers the services defined in the files in the services folder,
+// registering again any that have changed and deregistering any whose file has been removed.
+// If a file has errors, the service it defined before is kept until the file is fixed or removed.
+func (s *Server) syncServiceFiles(c registry.Caller) {
+	s.svcLock.Lock()
+	defer s.svcLock.Unlock()
+
+	dir := s.servicesDir()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		s.logError("Failed to read services folder", "dir", dir, LogError, err)
+		return
+	}
+	want := map[string]bool{}
+	files := map[string][]string{}
+	for _, fi := range entries {
+		if fi.IsDir() || !isServiceFile(fi.Name()) {
+			continue
+		}
+		p := filepath.Join(dir, fi.Name())
+		defs, err := readServiceFile(p)
+		if err != nil {
+			s.logError("Invalid service file", "file", p, LogError, err)
+			for _, id := range s.svcFiles[fi.Name()] {
+				want[id] = true
+				files[fi.Name()] = append(files[fi.Name()], id)
+			}
+			continue
+		}
+		for _, def := range defs {
+			if src := s.Registry.Source(def.ID); want[def.ID] || (src != "" && src != api.SourceFile) {
+				s.logError("Service ID in service file is already in use", "file", p, LogRegistrationID, def.ID)
+				continue
+			}
+			if err := s.registerDefinition(&def, api.SourceFile, c); err != nil {
+				s.logError("Service in service file was refused", "file", p, LogRegistrationID, def.ID, LogError, err)
+				continue
+			}
+			want[def.ID] = true
+			files[fi.Name()] = append(files[fi.Name()], def.ID)
+		}
+	}
+	s.svcFiles = files
+	for _, id := range s.Registry.IDs(api.SourceFile) {
+		if !want[id] {
+			s.Registry.Deregister(id, registry.ReasonReconfigure, c)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Brumawen/zcservice/src/api"
+	"github.com/Brumawen/zcservice/src/registry"
+)
+
+// ServiceDefinition defines a service that is announced without calling the web API,
+// such as a legacy daemon or an appliance.  It has the same properties as a RegisterRequest.
+type ServiceDefinition struct {
+	ID          string           `json:"id,omitempty"`          // ID of the service.  If blank, an ID is derived from the name, type, host and port
+	Name        string           `json:"name"`                  // Name of the service
+	PortNo      int              `json:"portNo"`                // Port number of the service
+	ServiceType string           `json:"serviceType,omitempty"` // Type of the service.  Defaults to the configured default service type
+	Subtypes    []string         `json:"subtypes,omitempty"`    // Subtypes the service can also be browsed by, e.g. "_printer"
+	Domain      string           `json:"domain,omitempty"`      // Service domain.  Defaults to "local."
+	Text        []string         `json:"text,omitempty"`        // Additional service Text
+	HealthCheck *api.HealthCheck `json:"healthCheck,omitempty"` // Health check that gates the announcement of the service
+	Host        string           `json:"host,omitempty"`        // Host name of the service, if it runs on another machine
+	IPs         []string         `json:"ips,omitempty"`         // IP addresses of Host.  Required if Host is specified
+	ExactName   bool             `json:"-"`                     // Announce Name as the instance name as it is, as Avahi does
+}
+
+// SetDefaults checks the values and sets the defaults.  The ID is derived
+// from the other values so that it stays the same across reloads.
+func (d *ServiceDefinition) SetDefaults() {
+	if d.ID == "" {
+		h := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%d", d.Name, d.ServiceType, d.Host, d.PortNo)))
+		d.ID = hex.EncodeToString(h[:16])
+	}
+}
+
+// Validate checks the service definition values
+func (d *ServiceDefinition) Validate() error {
+	if d.Name == "" {
+		return errors.New("service name is missing")
+	}
+	if d.PortNo <= 0 || d.PortNo > 65535 {
+		return fmt.Errorf("invalid port number %d", d.PortNo)
+	}
+	if d.HealthCheck != nil {
+		if err := d.HealthCheck.Validate(); err != nil {
+			return err
+		}
+	}
+	r := d.RegisterRequest()
+	return r.ValidateProxy()
+}
+
+// RegisterRequest returns a registration request for the service
+func (d *ServiceDefinition) RegisterRequest() *api.RegisterRequest {
+	return &api.RegisterRequest{
+		ID:          d.ID,
+		Name:        d.Name,
+		PortNo:      d.PortNo,
+		ServiceType: d.ServiceType,
+		Subtypes:    d.Subtypes,
+		Domain:      d.Domain,
+		Text:        d.Text,
+		HealthCheck: d.HealthCheck,
+		Host:        d.Host,
+		IPs:         d.IPs,
+		ExactName:   d.ExactName,
+	}
+}
+
+// registerDefinition checks the service definition in the same way as a registration through
+// the web API, and registers it again if it has changed.  A definition cannot replace a
+// registration from another source, such as this zcservice or the web API.
+func (s *Server) registerDefinition(def *ServiceDefinition, source string, c registry.Caller) error {
+	r := def.RegisterRequest()
+	if src := s.Registry.Source(r.ID); src != "" && src != source {
+		return &RequestError{409, "Service ID is in use by a " + src + " service."}
+	}
+	if err := s.checkHealthCheck(r.HealthCheck); err != nil {
+		return err
+	}
+	if s.Registry.IsChanged(r, source) {
+		s.Registry.Register(r, source, c)
+	}
+	return nil
+}
+
+// syncStaticServices registers the static services in the configuration, registering again
+// any that have changed and deregistering any that are no longer in the configuration
+func (s *Server) syncStaticServices(c registry.Caller) {
+	want := map[string]bool{}
+	cfg := s.Config()
+	for i := range cfg.Services {
+		def := &cfg.Services[i]
+		if err := s.registerDefinition(def, api.SourceStatic, c); err != nil {
+			s.logError("Static service was refused", LogRegistrationID, def.ID, LogError, err)
+			continue
+		}
+		want[def.ID] = true
+	}
+	for _, id := range s.Registry.IDs(api.SourceStatic) {
+		if !want[id] {
+			s.Registry.Deregister(id, registry.ReasonReconfigure, c)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package zcpb
+
+import (
+	"net"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Brumawen/zcservice/src/api"
+)
+
+// NewRegisterRequest returns the message for the registration request
+func NewRegisterRequest(r *api.RegisterRequest) *RegisterRequest {
+	return &RegisterRequest{
+		Id:          r.ID,
+		Name:        r.Name,
+		PortNo:      int32(r.PortNo),
+		ServiceType: r.ServiceType,
+		Subtypes:    r.Subtypes,
+		Domain:      r.Domain,
+		Text:        r.Text,
+		Pid:         int32(r.PID),
+		WatchOwner:  r.WatchOwner,
+		HealthCheck: NewHealthCheck(r.HealthCheck),
+		Host:        r.Host,
+		Ips:         r.IPs,
+	}
+}
+
+// ToAPI returns the registration request held in the message
+func (x *RegisterRequest) ToAPI() api.RegisterRequest {
+	return api.RegisterRequest{
+		ID:          x.GetId(),
+		Name:        x.GetName(),
+		PortNo:      int(x.GetPortNo()),
+		ServiceType: x.GetServiceType(),
+		Subtypes:    x.GetSubtypes(),
+		Domain:      x.GetDomain(),
+		Text:        x.GetText(),
+		PID:         int(x.GetPid()),
+		WatchOwner:  x.GetWatchOwner(),
+		HealthCheck: x.GetHealthCheck().ToAPI(),
+		Host:        x.GetHost(),
+		IPs:         x.GetIps(),
+	}
+}
+
+// NewHealthCheck returns the message for the health check, or nil if there is none
+func NewHealthCheck(c *api.HealthCheck) *HealthCheck {
+	if c == nil {
+		return nil
+	}
+	return &HealthCheck{
+		Type:               c.Type,
+		Host:               c.Host,
+		Path:               c.Path,
+		ExpectedStatus:     int32(c.ExpectedStatus),
+		Script:             c.Script,
+		Args:               c.Args,
+		Interval:           int32(c.Interval),
+		Timeout:            int32(c.Timeout),
+		HealthyThreshold:   int32(c.HealthyThreshold),
+		UnhealthyThreshold: int32(c.UnhealthyThreshold),
+		KeepAnnounced:      c.KeepAnnounced,
+	}
+}
+
+// ToAPI returns the health check held in the message, or nil if the message is nil
+func (x *HealthCheck) ToAPI() *api.HealthCheck {
+	if x == nil {
+		return nil
+	}
+	return &api.HealthCheck{
+		Type:               x.Type,
+		Host:               x.Host,
+		Path:               x.Path,
+		ExpectedStatus:     int(x.ExpectedStatus),
+		Script:             x.Script,
+		Args:               x.Args,
+		Interval:           int(x.Interval),
+		Timeout:            int(x.Timeout),
+		HealthyThreshold:   int(x.HealthyThreshold),
+		UnhealthyThreshold: int(x.UnhealthyThreshold),
+		KeepAnnounced:      x.KeepAnnounced,
+	}
+}
+
+// NewGetRequest returns the message for the search criteria
+func NewGetRequest(r *api.GetRequest) *GetRequest {
+	return &GetRequest{
+		ServiceType: r.ServiceType,
+		Domain:      r.Domain,
+		WaitTime:    int32(r.WaitTime),
+		OnlyHealthy: r.OnlyHealthy,
+	}
+}
+
+// ToAPI returns the search criteria held in the message
+func (x *GetRequest) ToAPI() api.GetRequest {
+	return api.GetRequest{
+		ServiceType: x.GetServiceType(),
+		Domain:      x.GetDomain(),
+		WaitTime:    int(x.GetWaitTime()),
+		OnlyHealthy: x.GetOnlyHealthy(),
+	}
+}
+
+// NewGetResponse returns the message for the services found
+func NewGetResponse(r *api.GetResponse) *GetResponse {
+	m := &GetResponse{
+		ServiceType: r.ServiceType,
+		Domain:      r.Domain,
+		Services:    make([]*ServiceItem, len(r.Services)),
+	}
+	for i := range r.Services {
+		m.Services[i] = NewServiceItem(&r.Services[i])
+	}
+	return m
+}
+
+// ToAPI returns the services found held in the message
+func (x *GetResponse) ToAPI() api.GetResponse {
+	r := api.GetResponse{
+		ServiceType: x.GetServiceType(),
+		Domain:      x.GetDomain(),
+		Services:    make([]api.ServiceItem, len(x.GetServices())),
+	}
+	for i, s := range x.GetServices() {
+		r.Services[i] = s.ToAPI()
+	}
+	return r
+}
+
+// NewServiceItem returns the message for the service instance
+func NewServiceItem(i *api.ServiceItem) *ServiceItem {
+	return &ServiceItem{
+		Name:     i.Name,
+		Port:     int32(i.Port),
+		Hostname: i.HostName,
+		Type:     i.Service,
+		Domain:   i.Domain,
+		Text:     i.Text,
+		Ipv4:     ipStrings(i.AddrIPv4),
+		Ipv6:     ipStrings(i.AddrIPv6),
+	}
+}
+
+// ToAPI returns the service instance held in the message.  Addresses that cannot be parsed are left out.
+func (x *ServiceItem) ToAPI() api.ServiceItem {
+	return api.ServiceItem{
+		Name:     x.GetName(),
+		Port:     int(x.GetPort()),
+		HostName: x.GetHostname(),
+		Service:  x.GetType(),
+		Domain:   x.GetDomain(),
+		Text:     x.GetText(),
+		AddrIPv4: parseIPs(x.GetIpv4()),
+		AddrIPv6: parseIPs(x.GetIpv6()),
+	}
+}
+
+// NewServiceEvent returns the message for the service event
+func NewServiceEvent(e *api.ServiceEvent) *ServiceEvent {
+	return &ServiceEvent{
+		Time:    timestamppb.New(e.Time),
+		Type:    e.Type,
+		Service: NewServiceItem(&e.Service),
+	}
+}
+
+// ToAPI returns the service event held in the message
+func (x *ServiceEvent) ToAPI() api.ServiceEvent {
+	return api.ServiceEvent{
+		Time:    x.GetTime().AsTime(),
+		Type:    x.GetType(),
+		Service: x.GetService().ToAPI(),
+	}
+}
+
+// ipStrings returns the IP addresses as strings
+func ipStrings(ips []net.IP) []string {
+	l := make([]string, len(ips))
+	for i, ip := range ips {
+		l[i] = ip.String()
+	}
+	return l
+}
+
+// parseIPs parses the IP address strings, leaving out any that are invalid
+func parseIPs(l []string) []net.IP {
+	ips := []net.IP{}
+	for _, s := range l {
+		if ip := net.ParseIP(s); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
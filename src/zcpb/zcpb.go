@@ -0,0 +1,5 @@
+// Package zcpb holds the protobuf messages and gRPC service definitions of the zcservice gRPC interface.
+// The code is generated from zcservice.proto.
+package zcpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative zcservice.proto